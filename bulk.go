@@ -0,0 +1,275 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// number of identities generated by each work unit
+// work units are the ordering and seeding granularity so changing it changes seeded output
+const BULK_CHUNK_SIZE = 1024
+
+// give up if this many consecutive chunks don't produce a single new unique identity
+const BULK_MAX_EMPTY_CHUNKS = 64
+
+// a complete generated identity
+type Identity struct {
+	ProductName string
+	Serial      string
+	MLB         string
+	ROM         string
+}
+
+type BulkOptions struct {
+	Params  Params // generation parameters, Index and Rand are set for each identity
+	Models  []int  // model indexes, each one gets Num identities
+	Num     int    // number of identities per model
+	Unique  bool   // skip identities sharing serial, MLB or ROM with a previous one
+	Workers int    // number of generation goroutines
+	Seed    int64  // seed for reproducible output, negative to use the secure generator
+}
+
+type BulkStats struct {
+	Generated  int // identities written
+	Duplicates int // identities skipped because they were not unique
+	Elapsed    time.Duration
+}
+
+func (s BulkStats) String() string {
+	rate := float64(s.Generated) / s.Elapsed.Seconds()
+	return fmt.Sprintf("Generated %d identities in %.2fs (%.0f/s), %d duplicates skipped", s.Generated, s.Elapsed.Seconds(), rate, s.Duplicates)
+}
+
+type bulkChunk struct {
+	index      int
+	identities []Identity
+	err        error
+}
+
+// splitmix64 mixes the seed so that consecutive chunks get unrelated random sources
+func splitmix64(x uint64) uint64 {
+	x += 0x9E3779B97F4A7C15
+	x = (x ^ (x >> 30)) * 0xBF58476D1CE4E5B9
+	x = (x ^ (x >> 27)) * 0x94D049BB133111EB
+	return x ^ (x >> 31)
+}
+
+// chunkRand returns the random source of a chunk
+// seeded chunks only depend on the seed, model and chunk index so the output doesn't depend on the workers
+func chunkRand(seed int64, model int, chunk int) *rand.Rand {
+	if seed < 0 {
+		return rand.New(cryptoSource{})
+	}
+	x := splitmix64(uint64(seed))
+	x = splitmix64(x ^ uint64(model))
+	x = splitmix64(x ^ uint64(chunk))
+	return rand.New(rand.NewSource(int64(x)))
+}
+
+// generateChunk generates all the identities of a chunk
+func generateChunk(opts *BulkOptions, model int, chunk int) bulkChunk {
+	ret := bulkChunk{index: chunk}
+	params := opts.Params
+	params.Index = model
+	params.Rand = chunkRand(opts.Seed, model, chunk)
+	for i := 0; i < chunkSize(opts.Num); i++ {
+		s, err := generateSerial(params)
+		if err != nil {
+			ret.err = err
+			return ret
+		}
+		mlb, err := s.MLB()
+		if err != nil {
+			ret.err = err
+			return ret
+		}
+		ret.identities = append(ret.identities, Identity{
+			ProductName: s.ProductName,
+			Serial:      s.String(),
+			MLB:         mlb,
+			ROM:         randomROM(params.Rand),
+		})
+	}
+	return ret
+}
+
+// chunkSize returns the number of identities of each chunk
+// small requests use a single chunk of the requested size to avoid generating unused identities
+func chunkSize(num int) int {
+	if num > 0 && num < BULK_CHUNK_SIZE {
+		return num
+	}
+	return BULK_CHUNK_SIZE
+}
+
+// exhaustedError explains which identity part ran out of unique values
+func exhaustedError(opts *BulkOptions, model int, count int, serials int, mlbs int, roms int) error {
+	dimension := "serial"
+	if mlbs > serials && mlbs >= roms {
+		dimension = "MLB"
+	} else if roms > serials && roms > mlbs {
+		dimension = "ROM"
+	}
+	err := fmt.Sprintf("Unable to generate more unique identities for %s after %d, the %s space is exhausted", ApplePlatformData[model].productName, count, dimension)
+	// the stable profile always uses the same model and board codes
+	if opts.Params.CodePick == CODE_PICK_FIRST {
+		err += ", use --profile realistic to spread them over all the model and board codes"
+	}
+	return fmt.Errorf("%s", err)
+}
+
+// generateBulk generates identities in parallel and calls out for each one in a deterministic order
+// generation stops at the first error returned by out
+func generateBulk(opts BulkOptions, out func(Identity) error) (BulkStats, error) {
+	stats := BulkStats{}
+	start := time.Now()
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	// no need for more workers than chunks
+	if chunks := (opts.Num + chunkSize(opts.Num) - 1) / chunkSize(opts.Num); !opts.Unique && chunks < opts.Workers {
+		opts.Workers = chunks
+	}
+
+	seenSerials := make(map[string]bool)
+	seenMLBs := make(map[string]bool)
+	seenROMs := make(map[string]bool)
+
+	for _, model := range opts.Models {
+		done := make(chan struct{})
+		jobs := make(chan int)
+		results := make(chan bulkChunk, opts.Workers)
+
+		var wg sync.WaitGroup
+		for w := 0; w < opts.Workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for chunk := range jobs {
+					select {
+					case results <- generateChunk(&opts, model, chunk):
+					case <-done:
+						return
+					}
+				}
+			}()
+		}
+		go func() {
+			defer close(jobs)
+			for chunk := 0; ; chunk++ {
+				select {
+				case jobs <- chunk:
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		// chunks finish out of order so keep them until it's their turn
+		pending := make(map[int]bulkChunk)
+		next := 0
+		count := 0
+		empty := 0
+		// duplicates of each part since the last chunk that added identities
+		dupSerials, dupMLBs, dupROMs := 0, 0, 0
+		var err error
+		for count < opts.Num && err == nil {
+			c := <-results
+			pending[c.index] = c
+			for {
+				c, ok := pending[next]
+				if !ok || count >= opts.Num || err != nil {
+					break
+				}
+				delete(pending, next)
+				next++
+				if c.err != nil {
+					err = c.err
+					break
+				}
+				added := 0
+				for _, id := range c.identities {
+					if count >= opts.Num {
+						break
+					}
+					if opts.Unique {
+						if seenSerials[id.Serial] || seenMLBs[id.MLB] || seenROMs[id.ROM] {
+							if seenSerials[id.Serial] {
+								dupSerials++
+							}
+							if seenMLBs[id.MLB] {
+								dupMLBs++
+							}
+							if seenROMs[id.ROM] {
+								dupROMs++
+							}
+							stats.Duplicates++
+							continue
+						}
+						seenSerials[id.Serial] = true
+						seenMLBs[id.MLB] = true
+						seenROMs[id.ROM] = true
+					}
+					if err = out(id); err != nil {
+						break
+					}
+					count++
+					added++
+					stats.Generated++
+				}
+				if added == 0 {
+					empty++
+					if empty == BULK_MAX_EMPTY_CHUNKS {
+						err = exhaustedError(&opts, model, count, dupSerials, dupMLBs, dupROMs)
+					}
+				} else {
+					empty = 0
+					dupSerials, dupMLBs, dupROMs = 0, 0, 0
+				}
+			}
+		}
+		close(done)
+		wg.Wait()
+		if err != nil {
+			stats.Elapsed = time.Since(start)
+			return stats, err
+		}
+	}
+	stats.Elapsed = time.Since(start)
+	return stats, nil
+}
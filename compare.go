@@ -0,0 +1,190 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// the fields of a MLB, see Serial.MLBCandidates for the layout
+type MLBInfo struct {
+	Country string
+	Year    string
+	Week    string
+	Block1  string // CCC code for legacy MLBs
+	Block2  string // empty for legacy MLBs
+	Board   string
+	Block3  string // suffix for legacy MLBs
+	Legacy  bool
+}
+
+// parseMLB splits a MLB into its fields
+func parseMLB(mlb string) (MLBInfo, error) {
+	switch len(mlb) {
+	case MLB_OLD_LEN:
+		// skip the 0 before the code
+		return MLBInfo{Country: mlb[:2], Year: mlb[2:3], Week: mlb[3:5], Block1: mlb[6:9], Board: mlb[9:12], Block3: mlb[12:], Legacy: true}, nil
+	case MLB_NEW_LEN:
+		return MLBInfo{Country: mlb[:3], Year: mlb[3:4], Week: mlb[4:6], Block1: mlb[6:9], Block2: mlb[9:11], Board: mlb[11:15], Block3: mlb[15:]}, nil
+	}
+	return MLBInfo{}, fmt.Errorf("Invalid MLB length: %d", len(mlb))
+}
+
+// parseIdentity parses an identity in the serial[,mlb[,rom]] format, fields can be empty
+func parseIdentity(str string) (Identity, error) {
+	parts := strings.Split(str, ",")
+	if len(parts) > 3 {
+		return Identity{}, fmt.Errorf("Invalid identity %s, use serial[,mlb[,rom]]", str)
+	}
+	id := Identity{Serial: normalizeSerial(parts[0])}
+	if len(parts) > 1 {
+		id.MLB = strings.ToUpper(strings.TrimSpace(parts[1]))
+		if _, err := parseMLB(id.MLB); id.MLB != "" && err != nil {
+			return Identity{}, err
+		}
+	}
+	if len(parts) > 2 {
+		id.ROM = strings.ToUpper(strings.TrimSpace(parts[2]))
+	}
+	return id, nil
+}
+
+// productionDate returns the first day of the production week
+func productionDate(year, week int) time.Time {
+	return time.Date(year, 1, 1+7*(week-1), 0, 0, 0, 0, time.UTC)
+}
+
+type Comparison struct {
+	SerialA       string `json:"serial_a"`
+	SerialB       string `json:"serial_b"`
+	SameSerial    bool   `json:"same_serial"`
+	SameCountry   bool   `json:"same_country"`
+	SameWeek      bool   `json:"same_week"`
+	WeekDistance  int    `json:"week_distance"` // -1 if any of the dates is invalid
+	SameLine      bool   `json:"same_line"`
+	Derivative    bool   `json:"derivative"` // same line but different copy
+	SameModelCode bool   `json:"same_model_code"`
+	SameModel     bool   `json:"same_model"`
+	// only set for identities
+	SameMLB      *bool `json:"same_mlb,omitempty"`
+	SameMLBBoard *bool `json:"same_mlb_board,omitempty"`
+	SameMLBBlock *bool `json:"same_mlb_blocks,omitempty"`
+	SameROM      *bool `json:"same_rom,omitempty"`
+	SameROMOUI   *bool `json:"same_rom_oui,omitempty"`
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// compareSerials reports the relationship between two decoded serials
+func compareSerials(a, b Serial) Comparison {
+	c := Comparison{SerialA: a.String(), SerialB: b.String(), WeekDistance: -1}
+	c.SameSerial = c.SerialA == c.SerialB
+	c.SameCountry = a.Country == b.Country
+	c.SameModelCode = a.Model == b.Model
+	c.SameModel = a.ProductName != "" && a.ProductName == b.ProductName
+	if a.DecodedYear > 0 && a.DecodedWeek > 0 && b.DecodedYear > 0 && b.DecodedWeek > 0 {
+		c.SameWeek = a.DecodedYear == b.DecodedYear && a.DecodedWeek == b.DecodedWeek
+		days := productionDate(a.DecodedYear, a.DecodedWeek).Sub(productionDate(b.DecodedYear, b.DecodedWeek)).Hours() / 24
+		if days < 0 {
+			days = -days
+		}
+		c.WeekDistance = int(days) / 7
+	}
+	// the line is only the same if produced in the same factory and week
+	c.SameLine = c.SameCountry && c.SameWeek && a.DecodedLine == b.DecodedLine
+	c.Derivative = c.SameLine && c.SameModelCode && a.DecodedCopy != b.DecodedCopy
+	return c
+}
+
+// compareIdentities reports the relationship between two identities
+func compareIdentities(a, b Identity) (Comparison, error) {
+	sa, err := decodeSerial(a.Serial, false)
+	if err != nil {
+		return Comparison{}, err
+	}
+	sb, err := decodeSerial(b.Serial, false)
+	if err != nil {
+		return Comparison{}, err
+	}
+	c := compareSerials(sa, sb)
+	if a.MLB != "" && b.MLB != "" {
+		c.SameMLB = boolPtr(a.MLB == b.MLB)
+		ma, _ := parseMLB(a.MLB)
+		mb, _ := parseMLB(b.MLB)
+		c.SameMLBBoard = boolPtr(ma.Board == mb.Board)
+		c.SameMLBBlock = boolPtr(ma.Block1 == mb.Block1 && ma.Block2 == mb.Block2 && ma.Block3 == mb.Block3)
+	}
+	if a.ROM != "" && b.ROM != "" {
+		c.SameROM = boolPtr(a.ROM == b.ROM)
+		c.SameROMOUI = boolPtr(len(a.ROM) >= 6 && len(b.ROM) >= 6 && a.ROM[:6] == b.ROM[:6])
+	}
+	return c, nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func (c *Comparison) Print() {
+	fmt.Printf("%14s: %s\n", "Serial A", c.SerialA)
+	fmt.Printf("%14s: %s\n", "Serial B", c.SerialB)
+	fmt.Printf("%14s: %s\n", "Same serial", yesNo(c.SameSerial))
+	fmt.Printf("%14s: %s\n", "Same factory", yesNo(c.SameCountry))
+	fmt.Printf("%14s: %s\n", "Same week", yesNo(c.SameWeek))
+	if c.WeekDistance >= 0 {
+		fmt.Printf("%14s: %d\n", "Week distance", c.WeekDistance)
+	} else {
+		fmt.Printf("%14s: %s\n", "Week distance", "Unknown")
+	}
+	fmt.Printf("%14s: %s\n", "Same line", yesNo(c.SameLine))
+	fmt.Printf("%14s: %s\n", "Derivative", yesNo(c.Derivative))
+	fmt.Printf("%14s: %s\n", "Same code", yesNo(c.SameModelCode))
+	fmt.Printf("%14s: %s\n", "Same model", yesNo(c.SameModel))
+	if c.SameMLB != nil {
+		fmt.Printf("%14s: %s\n", "Same MLB", yesNo(*c.SameMLB))
+		fmt.Printf("%14s: %s\n", "Same board", yesNo(*c.SameMLBBoard))
+		fmt.Printf("%14s: %s\n", "Same blocks", yesNo(*c.SameMLBBlock))
+	}
+	if c.SameROM != nil {
+		fmt.Printf("%14s: %s\n", "Same ROM", yesNo(*c.SameROM))
+		fmt.Printf("%14s: %s\n", "Same ROM OUI", yesNo(*c.SameROMOUI))
+	}
+}
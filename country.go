@@ -0,0 +1,169 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// how the production location is chosen when not set by the user
+const (
+	COUNTRY_PICK_BASE      = iota // location of the model base serial, original macserial behavior
+	COUNTRY_PICK_PLAUSIBLE        // any location used by models produced in the same years
+)
+
+// names of the COUNTRY_PICK_* modes as used in the command line
+var CountryPickNames = []string{"base", "plausible"}
+
+// refurbished units are not a factory so never pick it
+const REFURBISHED_LOCATION = "RM"
+
+// findLocation returns the location table index of the country or -1 if unknown
+// the table is selected by the country length
+func findLocation(country string) int {
+	var index map[string]int
+	switch len(country) {
+	case COUNTRY_NEW_LEN:
+		index = locationIndex
+	case COUNTRY_OLD_LEN:
+		index = legacyLocationIndex
+	}
+	if i, ok := index[country]; ok {
+		return i
+	}
+	return -1
+}
+
+// validateCountry checks if the country is a known production location
+func validateCountry(country string) error {
+	if len(country) != COUNTRY_OLD_LEN && len(country) != COUNTRY_NEW_LEN {
+		return fmt.Errorf("Country location %s is neither %d nor %d symbols long", country, COUNTRY_OLD_LEN, COUNTRY_NEW_LEN)
+	}
+	if findLocation(country) < 0 {
+		return fmt.Errorf("Unknown country location %s (see --list for valid locations)", country)
+	}
+	return nil
+}
+
+// modelsOverlap checks if two models share at least one production year
+func modelsOverlap(a, b AppleModel) bool {
+	for i := 0; i < APPLE_MODEL_YEAR_MAX && AppleModelYear[a][i] > 0; i++ {
+		if modelHasYear(b, int(AppleModelYear[a][i])) {
+			return true
+		}
+	}
+	return false
+}
+
+// plausibleCountries returns the known locations found in base serials of models
+// produced in the same years as the model, with the number of models using each one
+// the model own location is always included even if unknown, if the format matches
+func plausibleCountries(model AppleModel, size int) ([]string, []int) {
+	var countries []string
+	var weights []int
+	add := func(country string) {
+		for i := range countries {
+			if countries[i] == country {
+				weights[i]++
+				return
+			}
+		}
+		countries = append(countries, country)
+		weights = append(weights, 1)
+	}
+
+	// the serial length tells the format, and only that format country length is valid
+	serialLen := SERIAL_NEW_LEN
+	if size == COUNTRY_OLD_LEN {
+		serialLen = SERIAL_OLD_LEN
+	}
+	base := ApplePlatformData[model].serialNumber
+	if len(base) == serialLen {
+		add(base[:size])
+	}
+	for i := 0; i < len(ApplePlatformData); i++ {
+		other := ApplePlatformData[i].serialNumber
+		if AppleModel(i) == model || len(other) != serialLen || !modelsOverlap(model, AppleModel(i)) {
+			continue
+		}
+		country := other[:size]
+		if country == REFURBISHED_LOCATION || findLocation(country) < 0 {
+			continue
+		}
+		add(country)
+	}
+	return countries, weights
+}
+
+// pickCountry chooses a production location according to the COUNTRY_PICK_* mode using the random source r
+// a negative model means the model is unknown and only the format is considered
+func pickCountry(model int, size int, mode int, r *rand.Rand) string {
+	if model < 0 {
+		table := AppleLocations
+		if size == COUNTRY_OLD_LEN {
+			table = AppleLegacyLocations
+		}
+		if mode == COUNTRY_PICK_BASE {
+			return table[0]
+		}
+		for {
+			country := table[pseudoRandom(r)%len(table)]
+			if country != REFURBISHED_LOCATION {
+				return country
+			}
+		}
+	}
+
+	if mode == COUNTRY_PICK_BASE {
+		return ApplePlatformData[model].serialNumber[:size]
+	}
+
+	countries, weights := plausibleCountries(AppleModel(model), size)
+	if len(countries) == 0 {
+		return ApplePlatformData[model].serialNumber[:size]
+	}
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	n := pseudoRandom(r) % total
+	for i, w := range weights {
+		n -= w
+		if n < 0 {
+			return countries[i]
+		}
+	}
+	return countries[0]
+}
@@ -0,0 +1,403 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const procCPUInfo = "/proc/cpuinfo"
+
+// Intel microarchitectures in release order, the distance between them ranks the models
+var cpuArchs = []string{
+	"Yonah",
+	"Merom",
+	"Penryn",
+	"Nehalem",
+	"Westmere",
+	"Sandy Bridge",
+	"Ivy Bridge",
+	"Haswell",
+	"Broadwell",
+	"Skylake",
+	"Kaby Lake",
+	"Amber Lake",
+	"Coffee Lake",
+	"Cascade Lake",
+	"Comet Lake",
+	"Ice Lake",
+	"Tiger Lake",
+	"Alder Lake",
+	"Raptor Lake",
+}
+
+// Core generations from the second one, the first ones are handled by the model number
+var cpuCoreGenArchs = map[int]string{
+	2:  "Sandy Bridge",
+	3:  "Ivy Bridge",
+	4:  "Haswell",
+	5:  "Broadwell",
+	6:  "Skylake",
+	7:  "Kaby Lake",
+	8:  "Coffee Lake",
+	9:  "Coffee Lake",
+	10: "Comet Lake",
+	11: "Tiger Lake",
+	12: "Alder Lake",
+	13: "Raptor Lake",
+	14: "Raptor Lake",
+}
+
+// Core generation of the microarchitectures used by the Xeon and Core 2 parts
+var cpuArchGenerations = map[string]int{
+	"Nehalem":      1,
+	"Westmere":     1,
+	"Sandy Bridge": 2,
+	"Ivy Bridge":   3,
+	"Haswell":      4,
+	"Broadwell":    5,
+	"Skylake":      6,
+	"Kaby Lake":    7,
+}
+
+// Xeon E3/E5/E7 versions
+var cpuXeonVersionArchs = map[string]string{
+	"":   "Sandy Bridge",
+	"v2": "Ivy Bridge",
+	"v3": "Haswell",
+	"v4": "Broadwell",
+	"v5": "Skylake",
+	"v6": "Kaby Lake",
+}
+
+type CPUInfo struct {
+	Name   string `json:"name"`
+	Vendor string `json:"vendor"`
+	Brand  string `json:"brand,omitempty"`  // Core i7, Xeon, Core 2 Duo...
+	Number string `json:"number,omitempty"` // processor number like 8700K
+	// Intel Core generation, 0 if older than the Core i series or unknown
+	Generation int    `json:"generation"`
+	Arch       string `json:"microarchitecture,omitempty"`
+	Mobile     bool   `json:"mobile"`
+}
+
+var (
+	cpuNoise     = regexp.MustCompile(`\((R|TM|tm|r)\)|\bCPU\b|\b\d+(st|nd|rd|th) Gen\b|\bProcessor\b`)
+	cpuSpaces    = regexp.MustCompile(`\s+`)
+	cpuCoreI     = regexp.MustCompile(`\bCore (i[3579])[- ](\d{3,5})([A-Za-z]*\d?)\b`)
+	cpuCoreM     = regexp.MustCompile(`\bCore (m[357])?[- ]?(\d)(Y\d\d)\b|\bCore M[- ](\d)(Y\d\d)\b`)
+	cpuCore2     = regexp.MustCompile(`\bCore (2 Duo|2 Quad|2 Extreme|Duo|Solo) ([A-Z]{1,2})(\d{4})\b`)
+	cpuXeonE     = regexp.MustCompile(`\bXeon E([357])-(\d{4})[A-Z]*( v\d)?\b`)
+	cpuXeonW     = regexp.MustCompile(`\bXeon W-([23])(\d{3})[A-Z]*\b`)
+	cpuXeonOld   = regexp.MustCompile(`\bXeon ([EXLW]?)(\d)(\d)(\d\d)\b`)
+	cpuMobileSf  = regexp.MustCompile(`^(M|QM|XM|U|H|HQ|HK|HS|Y|G\d|NG\d|P)$`)
+	cpuAMDMobile = regexp.MustCompile(`\b\d{4}(U|H|HS|HX)\b`)
+)
+
+// normalizeCPUName removes the trademarks and filler words of /proc/cpuinfo model names
+func normalizeCPUName(name string) string {
+	name = cpuNoise.ReplaceAllString(name, " ")
+	return strings.TrimSpace(cpuSpaces.ReplaceAllString(name, " "))
+}
+
+// parseCPU extracts the structured information of a CPU name
+// the microarchitecture is empty if the CPU is not known
+func parseCPU(name string) CPUInfo {
+	name = normalizeCPUName(name)
+	c := CPUInfo{Name: name}
+	switch {
+	case strings.Contains(name, "Intel"):
+		c.Vendor = "Intel"
+	case strings.Contains(name, "AMD"):
+		c.Vendor = "AMD"
+		c.Mobile = cpuAMDMobile.MatchString(name)
+		return c
+	case strings.Contains(name, "Apple"):
+		c.Vendor = "Apple"
+		return c
+	}
+
+	if m := cpuCoreI.FindStringSubmatch(name); m != nil {
+		digits, suffix := m[2], strings.ToUpper(m[3])
+		c.Brand, c.Number = "Core "+m[1], digits+suffix
+		switch {
+		case len(digits) == 3:
+			// first generation, Lynnfield and Bloomfield are 7xx-9xx
+			c.Arch = "Westmere"
+			if digits[0] >= '7' {
+				c.Arch = "Nehalem"
+			}
+		case len(digits) == 5 || digits[0] == '1':
+			// 10th generation onwards like 10500 or the mobile 1038NG7
+			c.Generation, _ = strconv.Atoi(digits[:2])
+		default:
+			c.Generation = int(digits[0] - '0')
+		}
+		if c.Generation > 1 {
+			c.Arch = cpuCoreGenArchs[c.Generation]
+		}
+		// G and NG are the 10th generation Ice Lake and Y the 8th generation Amber Lake
+		if c.Generation == 10 && strings.Contains(suffix, "G") {
+			c.Arch = "Ice Lake"
+		}
+		if c.Generation == 8 && suffix == "Y" {
+			c.Arch = "Amber Lake"
+		}
+		c.Mobile = cpuMobileSf.MatchString(suffix)
+	} else if m := cpuCoreM.FindStringSubmatch(name); m != nil {
+		c.Brand, c.Mobile = "Core M", true
+		gen, number := m[2], m[3]
+		if m[1] != "" {
+			c.Brand = "Core " + m[1]
+		} else if m[4] != "" {
+			gen, number = m[4], m[5]
+		}
+		c.Number = gen + number
+		c.Generation = int(gen[0] - '0')
+		c.Arch = cpuCoreGenArchs[c.Generation]
+	} else if m := cpuCore2.FindStringSubmatch(name); m != nil {
+		c.Brand, c.Number = "Core "+m[1], m[2]+m[3]
+		c.Mobile = m[2] != "E" && m[2] != "Q" && m[2] != "X" && m[2] != "QX"
+		switch {
+		case m[1] == "Duo" || m[1] == "Solo":
+			c.Arch = "Yonah"
+		case m[3][0] >= '8' || m[2] == "P" || m[2] == "SL" || m[2] == "SU" || (m[2] == "E" && m[3][0] >= '7'):
+			// 45nm parts are E7xxx, E8xxx, T8xxx, T9xxx and all the P and SL/SU series
+			c.Arch = "Penryn"
+		default:
+			c.Arch = "Merom"
+		}
+	} else if m := cpuXeonE.FindStringSubmatch(name); m != nil {
+		c.Brand, c.Number = "Xeon", "E"+m[1]+"-"+m[2]+m[3]
+		c.Arch = cpuXeonVersionArchs[strings.TrimSpace(m[3])]
+	} else if m := cpuXeonW.FindStringSubmatch(name); m != nil {
+		c.Brand, c.Number = "Xeon", "W-"+m[1]+m[2]
+		c.Arch = "Skylake"
+		if m[1] == "3" {
+			c.Arch = "Cascade Lake"
+		}
+	} else if m := cpuXeonOld.FindStringSubmatch(name); m != nil {
+		c.Brand, c.Number = "Xeon", m[1]+m[2]+m[3]+m[4]
+		// 5100 and 5300 are Core based, 5400 Penryn, 3500 and 5500 Nehalem, 3600 and 5600 Westmere
+		switch m[3] {
+		case "1", "3":
+			c.Arch = "Merom"
+		case "4":
+			c.Arch = "Penryn"
+		case "5":
+			c.Arch = "Nehalem"
+		case "6":
+			c.Arch = "Westmere"
+		}
+	}
+	if c.Brand == "" && strings.Contains(name, "Xeon") {
+		c.Brand = "Xeon"
+	}
+	if c.Generation == 0 {
+		c.Generation = cpuArchGenerations[c.Arch]
+	}
+	return c
+}
+
+// archIndex returns the position of the microarchitecture in cpuArchs, -1 if unknown
+func archIndex(arch string) int {
+	for i, a := range cpuArchs {
+		if a == arch {
+			return i
+		}
+	}
+	return -1
+}
+
+// modelCPU returns the CPU of the model
+func modelCPU(model AppleModel) CPUInfo {
+	if int(model) >= len(AppleModelCPU) {
+		return CPUInfo{}
+	}
+	return parseCPU(AppleModelCPU[model])
+}
+
+// isLaptop checks if the model is a laptop, the CPU suffixes are not reliable for the older models
+func isLaptop(model AppleModel) bool {
+	return strings.HasPrefix(ApplePlatformData[model].productName, "MacBook")
+}
+
+// hostCPU reads the model name of the first processor in /proc/cpuinfo
+func hostCPU(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if found && strings.TrimSpace(key) == "model name" {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("No CPU model name found in %s", path)
+}
+
+type RecommendedModel struct {
+	Model   string   `json:"model"`
+	CPU     CPUInfo  `json:"cpu"`
+	Score   int      `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+type Recommendation struct {
+	CPU    CPUInfo            `json:"cpu"`
+	Models []RecommendedModel `json:"models"`
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// recommendModels ranks the models of the same form factor as the host CPU
+// by microarchitecture distance, CPU brand and macOS support
+// models not supported by the target macOS version (if any) are skipped
+func recommendModels(cpu CPUInfo, target string, version []int) Recommendation {
+	r := Recommendation{CPU: cpu, Models: []RecommendedModel{}}
+	hostArch := archIndex(cpu.Arch)
+	for i := range ApplePlatformData {
+		model := AppleModel(i)
+		if isLaptop(model) != cpu.Mobile {
+			continue
+		}
+		m := RecommendedModel{Model: ApplePlatformData[i].productName, CPU: modelCPU(model)}
+		if cpu.Mobile {
+			m.Reasons = append(m.Reasons, "laptop like the host CPU")
+		} else {
+			m.Reasons = append(m.Reasons, "desktop like the host CPU")
+		}
+
+		arch := archIndex(m.CPU.Arch)
+		switch {
+		case hostArch < 0:
+			// unknown and AMD CPUs are closer to the newest Macs
+			m.Score = 100 - 5*(len(cpuArchs)-1-arch)
+			if cpu.Vendor == "AMD" {
+				m.Reasons = append(m.Reasons, "AMD CPUs have no Apple equivalent, newer models are preferred")
+			} else {
+				m.Reasons = append(m.Reasons, fmt.Sprintf("the microarchitecture of %s is unknown, newer models are preferred", cpu.Name))
+			}
+		case arch == hostArch:
+			m.Score = 100
+			m.Reasons = append(m.Reasons, fmt.Sprintf("%s is also %s", m.CPU.Name, cpu.Arch))
+		default:
+			distance := arch - hostArch
+			if distance < 0 {
+				distance = -distance
+			}
+			m.Score = 100 - 10*distance
+			relation := "older"
+			if arch > hostArch {
+				relation = "newer"
+			}
+			m.Reasons = append(m.Reasons, fmt.Sprintf("%s is %s, %s %s than %s", m.CPU.Name, m.CPU.Arch, plural(distance, "generation"), relation, cpu.Arch))
+		}
+		if cpu.Brand != "" && (cpu.Brand == "Xeon") == (m.CPU.Brand == "Xeon") {
+			m.Score += 3
+			if cpu.Brand == "Xeon" {
+				m.Reasons = append(m.Reasons, "Xeon like the host CPU")
+			}
+		}
+
+		macos := modelMacOS(model)
+		if version != nil {
+			supported, known := macos.Supports(version)
+			switch {
+			case known && !supported:
+				continue
+			case known:
+				m.Reasons = append(m.Reasons, fmt.Sprintf("supports macOS %s", target))
+			default:
+				m.Score -= 5
+				m.Reasons = append(m.Reasons, fmt.Sprintf("support of macOS %s is unknown", target))
+			}
+		} else if macos.Known() && macos.Max == "" {
+			m.Score += 5
+			m.Reasons = append(m.Reasons, "still supported by the latest macOS")
+		} else if macos.Known() {
+			m.Reasons = append(m.Reasons, fmt.Sprintf("last supported macOS is %s", macos.Max))
+		}
+		r.Models = append(r.Models, m)
+	}
+	// newer models first when the score is the same
+	sort.SliceStable(r.Models, func(i, j int) bool {
+		if r.Models[i].Score != r.Models[j].Score {
+			return r.Models[i].Score > r.Models[j].Score
+		}
+		return archIndex(r.Models[i].CPU.Arch) > archIndex(r.Models[j].CPU.Arch)
+	})
+	return r
+}
+
+func (r *Recommendation) Print(num int) {
+	arch := r.CPU.Arch
+	if arch == "" {
+		arch = "unknown microarchitecture"
+	}
+	kind := "desktop"
+	if r.CPU.Mobile {
+		kind = "laptop"
+	}
+	fmt.Printf("Host CPU: %s (%s, %s)\n", r.CPU.Name, arch, kind)
+	if len(r.Models) == 0 {
+		fmt.Printf("No suitable models found\n")
+		return
+	}
+	fmt.Printf("Recommended models:\n")
+	for i := 0; i < len(r.Models) && i < num; i++ {
+		m := r.Models[i]
+		fmt.Printf("%2d. %s (score %d)\n", i+1, m.Model, m.Score)
+		for _, reason := range m.Reasons {
+			fmt.Printf("    - %s\n", reason)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a production week as encoded in the serial numbers
+// weeks are counted from the first day of the year and not ISO weeks
+type ProductionWeek struct {
+	Year int
+	Week int
+}
+
+func (p ProductionWeek) String() string {
+	return fmt.Sprintf("%04d-W%02d", p.Year, p.Week)
+}
+
+func (p ProductionWeek) before(o ProductionWeek) bool {
+	return p.Year < o.Year || (p.Year == o.Year && p.Week < o.Week)
+}
+
+// parseProductionWeek parses a date in the YYYY-Www or YYYY-MM-DD formats
+func parseProductionWeek(date string) (ProductionWeek, error) {
+	date = strings.ToUpper(strings.TrimSpace(date))
+	if idx := strings.Index(date, "-W"); idx > 0 {
+		year, err := strconv.Atoi(date[:idx])
+		if err != nil {
+			return ProductionWeek{}, fmt.Errorf("Invalid year in date %s", date)
+		}
+		week, err := strconv.Atoi(date[idx+2:])
+		if err != nil {
+			return ProductionWeek{}, fmt.Errorf("Invalid week in date %s", date)
+		}
+		if year < SERIAL_YEAR_MIN || year > SERIAL_YEAR_MAX {
+			return ProductionWeek{}, fmt.Errorf("Year %d is out of valid range [%d, %d]", year, SERIAL_YEAR_MIN, SERIAL_YEAR_MAX)
+		}
+		if week < SERIAL_WEEK_MIN || week > SERIAL_WEEK_MAX {
+			return ProductionWeek{}, fmt.Errorf("Week %d is out of valid range [%d, %d]", week, SERIAL_WEEK_MIN, SERIAL_WEEK_MAX)
+		}
+		return ProductionWeek{Year: year, Week: week}, nil
+	}
+
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return ProductionWeek{}, fmt.Errorf("Invalid date %s, use YYYY-Www or YYYY-MM-DD", date)
+	}
+	if t.Year() < SERIAL_YEAR_MIN || t.Year() > SERIAL_YEAR_MAX {
+		return ProductionWeek{}, fmt.Errorf("Year %d is out of valid range [%d, %d]", t.Year(), SERIAL_YEAR_MIN, SERIAL_YEAR_MAX)
+	}
+	// same week numbering used by parseSerial to compute WeekStart
+	return ProductionWeek{Year: t.Year(), Week: (t.YearDay()-1)/7 + 1}, nil
+}
+
+// serialYears returns the production years that the serial format can encode
+func serialYears(legacy bool) (int, int) {
+	if legacy {
+		return SERIAL_YEAR_OLD_MIN, SERIAL_YEAR_OLD_MAX
+	}
+	return SERIAL_YEAR_NEW_MIN, SERIAL_YEAR_NEW_MAX
+}
+
+// productionWeeks returns all the weeks inside the range that the model could be produced
+// a negative model means any year the serial format can encode is accepted
+// the last week is too rare to care, so it is only returned if the range has no other week
+func productionWeeks(model int, legacy bool, from, to ProductionWeek) []ProductionWeek {
+	var ret []ProductionWeek
+	var last []ProductionWeek
+	minYear, maxYear := serialYears(legacy)
+	for year := from.Year; year <= to.Year; year++ {
+		if year < minYear || year > maxYear {
+			continue
+		}
+		if model >= 0 && !modelHasYear(AppleModel(model), year) {
+			continue
+		}
+		for week := SERIAL_WEEK_MIN; week <= SERIAL_WEEK_MAX; week++ {
+			p := ProductionWeek{Year: year, Week: week}
+			if p.before(from) || to.before(p) {
+				continue
+			}
+			if week == SERIAL_WEEK_MAX {
+				last = append(last, p)
+				continue
+			}
+			ret = append(ret, p)
+		}
+	}
+	if len(ret) == 0 {
+		return last
+	}
+	return ret
+}
+
+// pickProductionWeek picks a random production week inside the range using the random source r
+// legacy selects the year bounds of the old serial format
+func pickProductionWeek(model int, legacy bool, from, to ProductionWeek, r *rand.Rand) (ProductionWeek, error) {
+	if to.before(from) {
+		return ProductionWeek{}, fmt.Errorf("Date range %s to %s is reversed", from, to)
+	}
+	weeks := productionWeeks(model, legacy, from, to)
+	if len(weeks) > 0 {
+		return weeks[pseudoRandom(r)%len(weeks)], nil
+	}
+	minYear, maxYear := serialYears(legacy)
+	if to.Year < minYear || from.Year > maxYear {
+		format := "modern"
+		if legacy {
+			format = "legacy"
+		}
+		return ProductionWeek{}, fmt.Errorf("Date range %s to %s is out of valid %s range [%d, %d]", from, to, format, minYear, maxYear)
+	}
+	if model >= 0 {
+		for year := from.Year; year <= to.Year; year++ {
+			if modelHasYear(AppleModel(model), year) {
+				return ProductionWeek{}, fmt.Errorf("Date range %s to %s has no production weeks of %s", from, to, ApplePlatformData[model].productName)
+			}
+		}
+		return ProductionWeek{}, fmt.Errorf("Date range %s to %s does not overlap %s production years", from, to, ApplePlatformData[model].productName)
+	}
+	return ProductionWeek{}, fmt.Errorf("Date range %s to %s has no valid production weeks", from, to)
+}
@@ -0,0 +1,396 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// a model entry in a database overlay, existing models only replace the fields that are set
+type OverlayModel struct {
+	Name          string      `json:"name" yaml:"name"`
+	Serial        string      `json:"serial,omitempty" yaml:"serial"`
+	ModelCodes    []string    `json:"model_codes,omitempty" yaml:"model_codes"`
+	BoardCodes    []string    `json:"board_codes,omitempty" yaml:"board_codes"`
+	Years         []uint32    `json:"years,omitempty" yaml:"years"`
+	PreferredYear *uint32     `json:"preferred_year,omitempty" yaml:"preferred_year"`
+	SMBIOS        *SMBIOSInfo `json:"smbios,omitempty" yaml:"smbios"` // replaces all the SMBIOS values
+	MinMacOS      *string     `json:"min_macos,omitempty" yaml:"min_macos"`
+	MaxMacOS      *string     `json:"max_macos,omitempty" yaml:"max_macos"` // empty if still supported
+	CPU           string      `json:"cpu,omitempty" yaml:"cpu"`
+}
+
+// a product description in a database overlay, replaces the description of an existing code
+type OverlayDesc struct {
+	Code string `json:"code" yaml:"code"`
+	Name string `json:"name" yaml:"name"`
+}
+
+type Overlay struct {
+	Models       []OverlayModel `json:"models" yaml:"models"`
+	Descriptions []OverlayDesc  `json:"descriptions" yaml:"descriptions"`
+}
+
+// readOverlay loads a JSON or YAML (by extension) database overlay, unknown fields are errors
+func readOverlay(path string) (*Overlay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	o := &Overlay{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(o)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(o)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid database overlay %s: %s", path, err)
+	}
+	return o, nil
+}
+
+// validSerialChars checks that the serial only contains characters that can be decoded
+func validSerialChars(serial string) bool {
+	for i := 0; i < len(serial); i++ {
+		c := serial[i]
+		if (c < '0' || c > '9') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeOverlay merges the overlay over the model tables
+// the tables are only modified if all the entries are valid
+func mergeOverlay(o *Overlay) error {
+	platform := append([]PlatformData(nil), ApplePlatformData...)
+	codes := append([][APPLE_MODEL_CODE_MAX]string(nil), AppleModelCode...)
+	boards := append([][APPLE_BOARD_CODE_MAX]string(nil), AppleBoardCode...)
+	years := append([][APPLE_MODEL_YEAR_MAX]uint32(nil), AppleModelYear...)
+	preferred := append([]uint32(nil), ApplePreferredModelYear...)
+	descs := append([]AppleModelDescription(nil), AppleModelDesc...)
+	smbios := append([]SMBIOSInfo(nil), AppleSMBIOSInfo...)
+	macos := append([]MacOSSupport(nil), AppleMacOSSupport...)
+	cpus := append([]string(nil), AppleModelCPU...)
+
+	var changed []int
+	for _, m := range o.Models {
+		if m.Name == "" {
+			return fmt.Errorf("Model entry without name")
+		}
+		index := -1
+		for i := range platform {
+			if platform[i].productName == m.Name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			if m.Serial == "" || len(m.ModelCodes) == 0 || len(m.Years) == 0 || m.CPU == "" {
+				return fmt.Errorf("New model %s requires serial, model_codes, years and cpu", m.Name)
+			}
+			platform = append(platform, PlatformData{productName: m.Name})
+			codes = append(codes, [APPLE_MODEL_CODE_MAX]string{})
+			boards = append(boards, [APPLE_BOARD_CODE_MAX]string{})
+			years = append(years, [APPLE_MODEL_YEAR_MAX]uint32{})
+			preferred = append(preferred, 0)
+			smbios = append(smbios, SMBIOSInfo{})
+			macos = append(macos, MacOSSupport{})
+			cpus = append(cpus, "")
+			index = len(platform) - 1
+		}
+		if m.Serial != "" {
+			platform[index].serialNumber = strings.ToUpper(m.Serial)
+		}
+		if m.ModelCodes != nil {
+			if len(m.ModelCodes) > APPLE_MODEL_CODE_MAX {
+				return fmt.Errorf("Model %s has more than %d model codes", m.Name, APPLE_MODEL_CODE_MAX)
+			}
+			codes[index] = [APPLE_MODEL_CODE_MAX]string{}
+			for i, code := range m.ModelCodes {
+				codes[index][i] = strings.ToUpper(code)
+			}
+		}
+		if m.BoardCodes != nil {
+			if len(m.BoardCodes) > APPLE_BOARD_CODE_MAX {
+				return fmt.Errorf("Model %s has more than %d board codes", m.Name, APPLE_BOARD_CODE_MAX)
+			}
+			boards[index] = [APPLE_BOARD_CODE_MAX]string{}
+			for i, code := range m.BoardCodes {
+				boards[index][i] = strings.ToUpper(code)
+			}
+		}
+		if m.Years != nil {
+			if len(m.Years) > APPLE_MODEL_YEAR_MAX {
+				return fmt.Errorf("Model %s has more than %d years", m.Name, APPLE_MODEL_YEAR_MAX)
+			}
+			years[index] = [APPLE_MODEL_YEAR_MAX]uint32{}
+			copy(years[index][:], m.Years)
+		}
+		if m.PreferredYear != nil {
+			preferred[index] = *m.PreferredYear
+		}
+		if m.SMBIOS != nil {
+			smbios[index] = *m.SMBIOS
+		}
+		if m.MinMacOS != nil {
+			macos[index].Min = strings.TrimSpace(*m.MinMacOS)
+		}
+		if m.MaxMacOS != nil {
+			macos[index].Max = strings.TrimSpace(*m.MaxMacOS)
+		}
+		if m.CPU != "" {
+			cpus[index] = m.CPU
+		}
+		changed = append(changed, index)
+	}
+
+	// model codes are what identifies the model when decoding so they can't be shared
+	owner := make(map[string]int)
+	for i := range codes {
+		for j := 0; j < APPLE_MODEL_CODE_MAX && codes[i][j] != ""; j++ {
+			if _, ok := owner[codes[i][j]]; !ok {
+				owner[codes[i][j]] = i
+			}
+		}
+	}
+	// only the values set by the overlay are validated, the compiled tables have a few inconsistencies
+	for k, i := range changed {
+		m := o.Models[k]
+		name := platform[i].productName
+		serial := platform[i].serialNumber
+		if m.Serial != "" && ((len(serial) != SERIAL_OLD_LEN && len(serial) != SERIAL_NEW_LEN) || !validSerialChars(serial)) {
+			return fmt.Errorf("Model %s has invalid base serial %s", name, serial)
+		}
+		size := 3
+		if len(serial) == SERIAL_NEW_LEN {
+			size = 4
+		}
+		if m.ModelCodes != nil && codes[i][0] == "" {
+			return fmt.Errorf("Model %s has no model codes", name)
+		}
+		for j := 0; (m.Serial != "" || m.ModelCodes != nil) && j < APPLE_MODEL_CODE_MAX && codes[i][j] != ""; j++ {
+			code := codes[i][j]
+			if len(code) != size || !validSerialChars(code) {
+				return fmt.Errorf("Model %s has invalid model code %s for serial %s", name, code, serial)
+			}
+			if owner[code] != i {
+				return fmt.Errorf("Model code %s of %s is also used by %s", code, name, platform[owner[code]].productName)
+			}
+		}
+		// MLBs embed the board code so its size depends on the serial format too
+		for j := 0; (m.Serial != "" || m.BoardCodes != nil) && j < APPLE_BOARD_CODE_MAX && boards[i][j] != ""; j++ {
+			if len(boards[i][j]) != size || !validSerialChars(boards[i][j]) {
+				return fmt.Errorf("Model %s has invalid board code %s for serial %s", name, boards[i][j], serial)
+			}
+		}
+		if m.Years != nil && years[i][0] == 0 {
+			return fmt.Errorf("Model %s has no years", name)
+		}
+		for j := 0; m.Years != nil && j < APPLE_MODEL_YEAR_MAX && years[i][j] > 0; j++ {
+			if years[i][j] < SERIAL_YEAR_MIN || years[i][j] > SERIAL_YEAR_MAX {
+				return fmt.Errorf("Model %s year %d is out of valid range [%d, %d]", name, years[i][j], SERIAL_YEAR_MIN, SERIAL_YEAR_MAX)
+			}
+		}
+		if (m.Years != nil || m.PreferredYear != nil) && preferred[i] != 0 && !yearsContain(years[i], preferred[i]) {
+			return fmt.Errorf("Model %s preferred year %d is not one of its years", name, preferred[i])
+		}
+		if m.MinMacOS != nil || m.MaxMacOS != nil {
+			if err := validateMacOSSupport(macos[i]); err != nil {
+				return fmt.Errorf("Model %s macOS support: %s", name, err)
+			}
+		}
+	}
+
+	for _, d := range o.Descriptions {
+		if d.Code == "" || d.Name == "" {
+			return fmt.Errorf("Description entry requires code and name")
+		}
+		code := strings.ToUpper(d.Code)
+		found := false
+		for i := range descs {
+			if descs[i].code == code {
+				descs[i].name = d.Name
+				found = true
+			}
+		}
+		if !found {
+			descs = append(descs, AppleModelDescription{code: code, name: d.Name})
+		}
+	}
+
+	ApplePlatformData = platform
+	AppleModelCode = codes
+	AppleBoardCode = boards
+	AppleModelYear = years
+	ApplePreferredModelYear = preferred
+	AppleModelDesc = descs
+	AppleSMBIOSInfo = smbios
+	AppleMacOSSupport = macos
+	AppleModelCPU = cpus
+	buildIndexes()
+	return nil
+}
+
+// saveTables returns a function that restores the model tables to their current state
+func saveTables() func() {
+	platform, codes, boards, years := ApplePlatformData, AppleModelCode, AppleBoardCode, AppleModelYear
+	preferred, descs, smbios, macos := ApplePreferredModelYear, AppleModelDesc, AppleSMBIOSInfo, AppleMacOSSupport
+	cpus := AppleModelCPU
+	return func() {
+		ApplePlatformData, AppleModelCode, AppleBoardCode, AppleModelYear = platform, codes, boards, years
+		ApplePreferredModelYear, AppleModelDesc, AppleSMBIOSInfo, AppleMacOSSupport = preferred, descs, smbios, macos
+		AppleModelCPU = cpus
+		buildIndexes()
+	}
+}
+
+func yearsContain(years [APPLE_MODEL_YEAR_MAX]uint32, year uint32) bool {
+	for i := 0; i < APPLE_MODEL_YEAR_MAX && years[i] > 0; i++ {
+		if years[i] == year {
+			return true
+		}
+	}
+	return false
+}
+
+// loadDatabase merges the overlay file over the compiled model database
+func loadDatabase(path string) error {
+	o, err := readOverlay(path)
+	if err != nil {
+		return err
+	}
+	return mergeOverlay(o)
+}
+
+// checkDatabase verifies the consistency of the model tables and returns the problems found
+func checkDatabase() []string {
+	var problems []string
+	report := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	if len(AppleLocations) != len(AppleLocationNames) {
+		report("AppleLocations has %d entries but AppleLocationNames has %d", len(AppleLocations), len(AppleLocationNames))
+	}
+	if len(AppleLegacyLocations) != len(AppleLegacyLocationNames) {
+		report("AppleLegacyLocations has %d entries but AppleLegacyLocationNames has %d", len(AppleLegacyLocations), len(AppleLegacyLocationNames))
+	}
+	models := len(ApplePlatformData)
+	// overlays can add models but never remove them
+	if models < APPLE_MODEL_MAX {
+		report("ApplePlatformData has %d entries but APPLE_MODEL_MAX is %d", models, APPLE_MODEL_MAX)
+	}
+	for name, size := range map[string]int{
+		"AppleModelCode":          len(AppleModelCode),
+		"AppleBoardCode":          len(AppleBoardCode),
+		"AppleModelYear":          len(AppleModelYear),
+		"ApplePreferredModelYear": len(ApplePreferredModelYear),
+		"AppleSMBIOSInfo":         len(AppleSMBIOSInfo),
+		"AppleMacOSSupport":       len(AppleMacOSSupport),
+		"AppleModelCPU":           len(AppleModelCPU),
+	} {
+		if size != models {
+			report("%s has %d entries but ApplePlatformData has %d", name, size, models)
+		}
+	}
+	if len(problems) > 0 {
+		// the per model checks below would go out of bounds
+		sort.Strings(problems)
+		return problems
+	}
+
+	owner := make(map[string]int)
+	for i := 0; i < models; i++ {
+		name := ApplePlatformData[i].productName
+		// the same checks as parseSerial, an invalid week doesn't clear Valid
+		if s, err := decodeSerial(ApplePlatformData[i].serialNumber, false); err != nil {
+			report("%s base serial %s: %s", name, ApplePlatformData[i].serialNumber, err)
+		} else if !s.Valid || s.DecodedWeek < 0 || s.DecodedYear < 0 {
+			report("%s base serial %s is not valid: %s", name, ApplePlatformData[i].serialNumber, strings.Join(s.Warnings, " "))
+		}
+		if AppleModelCode[i][0] == "" {
+			report("%s has no model codes", name)
+		}
+		for j := 0; j < APPLE_MODEL_CODE_MAX && AppleModelCode[i][j] != ""; j++ {
+			code := AppleModelCode[i][j]
+			if other, ok := owner[code]; ok {
+				report("%s model code %s is also used by %s", name, code, ApplePlatformData[other].productName)
+			} else {
+				owner[code] = i
+			}
+		}
+		if AppleModelYear[i][0] == 0 {
+			report("%s has no years", name)
+		}
+		for j := 0; j < APPLE_MODEL_YEAR_MAX && AppleModelYear[i][j] > 0; j++ {
+			if AppleModelYear[i][j] < SERIAL_YEAR_MIN || AppleModelYear[i][j] > SERIAL_YEAR_MAX {
+				report("%s year %d is out of valid range [%d, %d]", name, AppleModelYear[i][j], SERIAL_YEAR_MIN, SERIAL_YEAR_MAX)
+			}
+		}
+		if ApplePreferredModelYear[i] != 0 && !yearsContain(AppleModelYear[i], ApplePreferredModelYear[i]) {
+			report("%s preferred year %d is not one of its years", name, ApplePreferredModelYear[i])
+		}
+		if err := validateMacOSSupport(AppleMacOSSupport[i]); err != nil {
+			report("%s macOS support: %s", name, err)
+		}
+		if AppleModelCPU[i] == "" {
+			report("%s has no CPU", name)
+		}
+	}
+
+	for i, block := range [][]string{MLBBlock1, MLBBlock2, MLBBlock3} {
+		seen := make(map[string]bool)
+		for _, b := range block {
+			if seen[b] {
+				report("MLBBlock%d has duplicate entry %s", i+1, b)
+			}
+			seen[b] = true
+		}
+	}
+	return problems
+}
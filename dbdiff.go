@@ -0,0 +1,548 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a model of a database snapshot used for comparisons
+type DBModel struct {
+	Name       string
+	Serial     string
+	ModelCodes []string
+	BoardCodes []string
+	Years      []uint32
+	Preferred  uint32
+	SMBIOS     SMBIOSInfo
+	MacOS      MacOSSupport
+	CPU        string
+}
+
+type ModelDB struct {
+	Models []DBModel
+	Desc   map[string]string
+}
+
+type ModelChange struct {
+	Name              string        `json:"name"`
+	OldSerial         string        `json:"old_serial,omitempty"`
+	NewSerial         string        `json:"new_serial,omitempty"`
+	AddedModelCodes   []string      `json:"added_model_codes,omitempty"`
+	RemovedModelCodes []string      `json:"removed_model_codes,omitempty"`
+	AddedBoardCodes   []string      `json:"added_board_codes,omitempty"`
+	RemovedBoardCodes []string      `json:"removed_board_codes,omitempty"`
+	OldYears          []uint32      `json:"old_years,omitempty"`
+	NewYears          []uint32      `json:"new_years,omitempty"`
+	OldPreferred      *uint32       `json:"old_preferred_year,omitempty"`
+	NewPreferred      *uint32       `json:"new_preferred_year,omitempty"`
+	SMBIOSChanged     []string      `json:"smbios_changed,omitempty"` // names of the changed SMBIOS values
+	OldMacOS          *MacOSSupport `json:"old_macos,omitempty"`
+	NewMacOS          *MacOSSupport `json:"new_macos,omitempty"`
+	OldCPU            string        `json:"old_cpu,omitempty"`
+	NewCPU            string        `json:"new_cpu,omitempty"`
+}
+
+type DescChange struct {
+	Code string `json:"code"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+type DBDiff struct {
+	AddedModels   []string      `json:"added_models,omitempty"`
+	RemovedModels []string      `json:"removed_models,omitempty"`
+	ChangedModels []ModelChange `json:"changed_models,omitempty"`
+	AddedDesc     []DescChange  `json:"added_descriptions,omitempty"`
+	RemovedDesc   []DescChange  `json:"removed_descriptions,omitempty"`
+	ChangedDesc   []DescChange  `json:"changed_descriptions,omitempty"`
+}
+
+// currentDB takes a snapshot of the model tables
+func currentDB() ModelDB {
+	db := ModelDB{Desc: make(map[string]string)}
+	for i := range ApplePlatformData {
+		m := DBModel{
+			Name:      ApplePlatformData[i].productName,
+			Serial:    ApplePlatformData[i].serialNumber,
+			Preferred: ApplePreferredModelYear[i],
+			SMBIOS:    modelSMBIOS(AppleModel(i)),
+			MacOS:     modelMacOS(AppleModel(i)),
+			CPU:       AppleModelCPU[i],
+		}
+		for j := 0; j < APPLE_MODEL_CODE_MAX && AppleModelCode[i][j] != ""; j++ {
+			m.ModelCodes = append(m.ModelCodes, AppleModelCode[i][j])
+		}
+		for j := 0; j < APPLE_BOARD_CODE_MAX && AppleBoardCode[i][j] != ""; j++ {
+			m.BoardCodes = append(m.BoardCodes, AppleBoardCode[i][j])
+		}
+		for j := 0; j < APPLE_MODEL_YEAR_MAX && AppleModelYear[i][j] > 0; j++ {
+			m.Years = append(m.Years, AppleModelYear[i][j])
+		}
+		db.Models = append(db.Models, m)
+	}
+	for _, d := range AppleModelDesc {
+		if _, ok := db.Desc[d.code]; !ok {
+			db.Desc[d.code] = d.name
+		}
+	}
+	return db
+}
+
+// overlayDB returns a snapshot of the model tables with the overlay merged, the tables are left untouched
+func overlayDB(path string) (ModelDB, error) {
+	defer saveTables()()
+	if err := loadDatabase(path); err != nil {
+		return ModelDB{}, err
+	}
+	return currentDB(), nil
+}
+
+// literal values of a generated table element
+func literalStrings(expr ast.Expr) []string {
+	var ret []string
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		if b, ok := expr.(*ast.BasicLit); ok {
+			lit = &ast.CompositeLit{Elts: []ast.Expr{b}}
+		} else {
+			return nil
+		}
+	}
+	for _, e := range lit.Elts {
+		b, ok := e.(*ast.BasicLit)
+		if !ok {
+			continue
+		}
+		v := b.Value
+		if b.Kind == token.STRING {
+			v, _ = strconv.Unquote(b.Value)
+		}
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+func parseYears(values []string) []uint32 {
+	var years []uint32
+	for _, v := range values {
+		if y, err := strconv.ParseUint(v, 10, 32); err == nil && y > 0 {
+			years = append(years, uint32(y))
+		}
+	}
+	return years
+}
+
+// literalSMBIOS reads a keyed SMBIOSInfo literal
+func literalSMBIOS(expr ast.Expr) SMBIOSInfo {
+	info := SMBIOSInfo{}
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return info
+	}
+	for _, e := range lit.Elts {
+		kv, ok := e.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		values := literalStrings(kv.Value)
+		ints := make([]int, 0, len(values))
+		for _, v := range values {
+			if n, err := strconv.ParseInt(v, 0, 64); err == nil {
+				ints = append(ints, int(n))
+			}
+		}
+		value := ""
+		if len(values) > 0 {
+			value = values[0]
+		}
+		number, _ := strconv.ParseUint(value, 0, 64)
+		switch key.Name {
+		case "BoardProduct":
+			info.BoardProduct = values
+		case "BIOSVersion":
+			info.BIOSVersion = value
+		case "BIOSReleaseDate":
+			info.BIOSReleaseDate = value
+		case "ChassisType":
+			info.ChassisType = int(number)
+		case "ChassisAssetTag":
+			info.ChassisAssetTag = value
+		case "FirmwareFeatures":
+			info.FirmwareFeatures = number
+		case "FirmwareFeaturesMask":
+			info.FirmwareFeaturesMask = number
+		case "SmcRevision":
+			info.SmcRevision = ints
+		case "SmcBranch":
+			info.SmcBranch = ints
+		case "SmcPlatform":
+			info.SmcPlatform = ints
+		}
+	}
+	return info
+}
+
+// literalMacOS reads a keyed MacOSSupport literal
+func literalMacOS(expr ast.Expr) MacOSSupport {
+	support := MacOSSupport{}
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return support
+	}
+	for _, e := range lit.Elts {
+		kv, ok := e.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		values := literalStrings(kv.Value)
+		if !ok || len(values) == 0 {
+			continue
+		}
+		switch key.Name {
+		case "Min":
+			support.Min = values[0]
+		case "Max":
+			support.Max = values[0]
+		}
+	}
+	return support
+}
+
+// smbiosChanges returns the names of the SMBIOS values that differ
+func smbiosChanges(a, b SMBIOSInfo) []string {
+	var changed []string
+	fa, fb := a.allFields(), b.allFields()
+	for i := range fa {
+		if fa[i][1] != fb[i][1] {
+			changed = append(changed, fa[i][0])
+		}
+	}
+	return changed
+}
+
+// readGeneratedDB reads the tables from a generated modelinfo_autogen.go file
+func readGeneratedDB(path string) (ModelDB, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		return ModelDB{}, err
+	}
+	tables := make(map[string][]ast.Expr)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, name := range vs.Names {
+				if i < len(vs.Values) {
+					if lit, ok := vs.Values[i].(*ast.CompositeLit); ok {
+						tables[name.Name] = lit.Elts
+					}
+				}
+			}
+		}
+	}
+	for _, name := range []string{"ApplePlatformData", "AppleModelCode", "AppleBoardCode", "AppleModelYear", "ApplePreferredModelYear", "AppleModelDesc"} {
+		if _, ok := tables[name]; !ok {
+			return ModelDB{}, fmt.Errorf("%s: missing %s table", path, name)
+		}
+	}
+	platform := tables["ApplePlatformData"]
+	for _, name := range []string{"AppleModelCode", "AppleBoardCode", "AppleModelYear", "ApplePreferredModelYear"} {
+		if len(tables[name]) != len(platform) {
+			return ModelDB{}, fmt.Errorf("%s: %s has %d entries but ApplePlatformData has %d", path, name, len(tables[name]), len(platform))
+		}
+	}
+
+	db := ModelDB{Desc: make(map[string]string)}
+	for i, e := range platform {
+		data := literalStrings(e)
+		if len(data) != 2 {
+			return ModelDB{}, fmt.Errorf("%s: invalid ApplePlatformData entry %d", path, i)
+		}
+		m := DBModel{Name: data[0], Serial: data[1]}
+		for _, code := range literalStrings(tables["AppleModelCode"][i]) {
+			if code != "" {
+				m.ModelCodes = append(m.ModelCodes, code)
+			}
+		}
+		for _, code := range literalStrings(tables["AppleBoardCode"][i]) {
+			if code != "" {
+				m.BoardCodes = append(m.BoardCodes, code)
+			}
+		}
+		m.Years = parseYears(literalStrings(tables["AppleModelYear"][i]))
+		if y := parseYears(literalStrings(tables["ApplePreferredModelYear"][i])); len(y) > 0 {
+			m.Preferred = y[0]
+		}
+		// older generated files don't have the SMBIOS table
+		if smbios, ok := tables["AppleSMBIOSInfo"]; ok && i < len(smbios) {
+			m.SMBIOS = literalSMBIOS(smbios[i])
+		}
+		if macos, ok := tables["AppleMacOSSupport"]; ok && i < len(macos) {
+			m.MacOS = literalMacOS(macos[i])
+		}
+		if cpus, ok := tables["AppleModelCPU"]; ok && i < len(cpus) {
+			if cpu := literalStrings(cpus[i]); len(cpu) == 1 {
+				m.CPU = cpu[0]
+			}
+		}
+		db.Models = append(db.Models, m)
+	}
+	for _, e := range tables["AppleModelDesc"] {
+		data := literalStrings(e)
+		if len(data) != 2 {
+			continue
+		}
+		if _, ok := db.Desc[data[0]]; !ok {
+			db.Desc[data[0]] = data[1]
+		}
+	}
+	return db, nil
+}
+
+// readDB reads a generated Go file or merges a JSON or YAML overlay over the current tables
+func readDB(path string) (ModelDB, error) {
+	if strings.ToLower(filepath.Ext(path)) == ".go" {
+		return readGeneratedDB(path)
+	}
+	return overlayDB(path)
+}
+
+// codeChanges returns the codes only in b and the codes only in a
+func codeChanges(a, b []string) (added []string, removed []string) {
+	in := func(list []string, code string) bool {
+		for _, c := range list {
+			if c == code {
+				return true
+			}
+		}
+		return false
+	}
+	for _, c := range b {
+		if !in(a, c) {
+			added = append(added, c)
+		}
+	}
+	for _, c := range a {
+		if !in(b, c) {
+			removed = append(removed, c)
+		}
+	}
+	return added, removed
+}
+
+func sameYears(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffDB compares two database snapshots, models are matched by product name
+func diffDB(a, b ModelDB) DBDiff {
+	diff := DBDiff{}
+	old := make(map[string]DBModel)
+	for _, m := range a.Models {
+		old[m.Name] = m
+	}
+	seen := make(map[string]bool)
+	for _, m := range b.Models {
+		seen[m.Name] = true
+		o, ok := old[m.Name]
+		if !ok {
+			diff.AddedModels = append(diff.AddedModels, m.Name)
+			continue
+		}
+		c := ModelChange{Name: m.Name}
+		changed := false
+		if o.Serial != m.Serial {
+			c.OldSerial, c.NewSerial = o.Serial, m.Serial
+			changed = true
+		}
+		c.AddedModelCodes, c.RemovedModelCodes = codeChanges(o.ModelCodes, m.ModelCodes)
+		c.AddedBoardCodes, c.RemovedBoardCodes = codeChanges(o.BoardCodes, m.BoardCodes)
+		if len(c.AddedModelCodes)+len(c.RemovedModelCodes)+len(c.AddedBoardCodes)+len(c.RemovedBoardCodes) > 0 {
+			changed = true
+		}
+		if !sameYears(o.Years, m.Years) {
+			c.OldYears, c.NewYears = o.Years, m.Years
+			changed = true
+		}
+		if o.Preferred != m.Preferred {
+			oldPreferred, newPreferred := o.Preferred, m.Preferred
+			c.OldPreferred, c.NewPreferred = &oldPreferred, &newPreferred
+			changed = true
+		}
+		if c.SMBIOSChanged = smbiosChanges(o.SMBIOS, m.SMBIOS); len(c.SMBIOSChanged) > 0 {
+			changed = true
+		}
+		if o.MacOS != m.MacOS {
+			oldMacOS, newMacOS := o.MacOS, m.MacOS
+			c.OldMacOS, c.NewMacOS = &oldMacOS, &newMacOS
+			changed = true
+		}
+		// older generated files only have the CPU in comments
+		if o.CPU != m.CPU && o.CPU != "" && m.CPU != "" {
+			c.OldCPU, c.NewCPU = o.CPU, m.CPU
+			changed = true
+		}
+		if changed {
+			diff.ChangedModels = append(diff.ChangedModels, c)
+		}
+	}
+	for _, m := range a.Models {
+		if !seen[m.Name] {
+			diff.RemovedModels = append(diff.RemovedModels, m.Name)
+		}
+	}
+
+	for code, name := range b.Desc {
+		oldName, ok := a.Desc[code]
+		if !ok {
+			diff.AddedDesc = append(diff.AddedDesc, DescChange{Code: code, New: name})
+		} else if oldName != name {
+			diff.ChangedDesc = append(diff.ChangedDesc, DescChange{Code: code, Old: oldName, New: name})
+		}
+	}
+	for code, name := range a.Desc {
+		if _, ok := b.Desc[code]; !ok {
+			diff.RemovedDesc = append(diff.RemovedDesc, DescChange{Code: code, Old: name})
+		}
+	}
+	for _, list := range [][]DescChange{diff.AddedDesc, diff.RemovedDesc, diff.ChangedDesc} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	}
+	return diff
+}
+
+// Empty checks if the databases are the same
+func (d *DBDiff) Empty() bool {
+	return len(d.AddedModels)+len(d.RemovedModels)+len(d.ChangedModels)+
+		len(d.AddedDesc)+len(d.RemovedDesc)+len(d.ChangedDesc) == 0
+}
+
+func joinYears(years []uint32) string {
+	str := make([]string, len(years))
+	for i, y := range years {
+		str[i] = fmt.Sprint(y)
+	}
+	return strings.Join(str, ", ")
+}
+
+func (d *DBDiff) Print() {
+	if d.Empty() {
+		fmt.Println("No differences")
+		return
+	}
+	if len(d.AddedModels) > 0 {
+		fmt.Printf("Added models:\n")
+		for _, m := range d.AddedModels {
+			fmt.Printf(" + %s\n", m)
+		}
+	}
+	if len(d.RemovedModels) > 0 {
+		fmt.Printf("Removed models:\n")
+		for _, m := range d.RemovedModels {
+			fmt.Printf(" - %s\n", m)
+		}
+	}
+	if len(d.ChangedModels) > 0 {
+		fmt.Printf("Changed models:\n")
+		for _, c := range d.ChangedModels {
+			fmt.Printf(" %s:\n", c.Name)
+			if c.OldSerial != c.NewSerial {
+				fmt.Printf("%16s: %s -> %s\n", "Base serial", c.OldSerial, c.NewSerial)
+			}
+			if len(c.AddedModelCodes) > 0 {
+				fmt.Printf("%16s: %s\n", "+ Model codes", strings.Join(c.AddedModelCodes, ", "))
+			}
+			if len(c.RemovedModelCodes) > 0 {
+				fmt.Printf("%16s: %s\n", "- Model codes", strings.Join(c.RemovedModelCodes, ", "))
+			}
+			if len(c.AddedBoardCodes) > 0 {
+				fmt.Printf("%16s: %s\n", "+ Board codes", strings.Join(c.AddedBoardCodes, ", "))
+			}
+			if len(c.RemovedBoardCodes) > 0 {
+				fmt.Printf("%16s: %s\n", "- Board codes", strings.Join(c.RemovedBoardCodes, ", "))
+			}
+			if c.OldYears != nil || c.NewYears != nil {
+				fmt.Printf("%16s: %s -> %s\n", "Years", joinYears(c.OldYears), joinYears(c.NewYears))
+			}
+			if c.OldPreferred != nil {
+				fmt.Printf("%16s: %d -> %d\n", "Preferred year", *c.OldPreferred, *c.NewPreferred)
+			}
+			if len(c.SMBIOSChanged) > 0 {
+				fmt.Printf("%16s: %s\n", "SMBIOS", strings.Join(c.SMBIOSChanged, ", "))
+			}
+			if c.OldMacOS != nil {
+				fmt.Printf("%16s: %s -> %s\n", "macOS", c.OldMacOS.String(), c.NewMacOS.String())
+			}
+			if c.OldCPU != "" {
+				fmt.Printf("%16s: %s -> %s\n", "CPU", c.OldCPU, c.NewCPU)
+			}
+		}
+	}
+	if len(d.AddedDesc)+len(d.RemovedDesc)+len(d.ChangedDesc) > 0 {
+		fmt.Printf("Descriptions:\n")
+		for _, c := range d.AddedDesc {
+			fmt.Printf(" + %4s - %s\n", c.Code, c.New)
+		}
+		for _, c := range d.RemovedDesc {
+			fmt.Printf(" - %4s - %s\n", c.Code, c.Old)
+		}
+		for _, c := range d.ChangedDesc {
+			fmt.Printf(" ~ %4s - %s -> %s\n", c.Code, c.Old, c.New)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// a serial from the same production line as another serial but with a different copy
+type Derivative struct {
+	Serial   string `json:"serial"`
+	Copy     int    `json:"copy"`
+	Original bool   `json:"original"`
+	Valid    bool   `json:"valid"`
+}
+
+type DerivOptions struct {
+	ExcludeOriginal bool // don't return the serial itself
+	CopyMin         int  // lowest copy returned
+	CopyMax         int  // highest copy returned
+	OnlyValid       bool // only return serials that parseSerial considers valid
+}
+
+// DefaultDerivOptions returns all the derivatives including the original serial
+func DefaultDerivOptions() DerivOptions {
+	return DerivOptions{CopyMin: SERIAL_COPY_MIN, CopyMax: SERIAL_COPY_MAX}
+}
+
+// parseCopyRange parses a copy range in the min-max format, a single number is also accepted
+func parseCopyRange(str string) (int, int, error) {
+	parts := strings.SplitN(str, "-", 2)
+	lo, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid copy range %s", str)
+	}
+	hi := lo
+	if len(parts) == 2 {
+		if hi, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, fmt.Errorf("Invalid copy range %s", str)
+		}
+	}
+	if lo < SERIAL_COPY_MIN || hi > SERIAL_COPY_MAX || lo > hi {
+		return 0, 0, fmt.Errorf("Copy range %s is out of valid range [%d, %d]", str, SERIAL_COPY_MIN, SERIAL_COPY_MAX)
+	}
+	return lo, hi, nil
+}
+
+// derivativeSerials returns all the copy variants of the serial production line
+func derivativeSerials(s Serial, opts DerivOptions) []Derivative {
+	var ret []Derivative
+	week := string(s.Week[:])
+	if !s.Legacy {
+		week = string(s.Week[:1])
+	}
+	original := s.String()
+	rmin := lineToRmin(s.DecodedLine)
+	for k := 0; k < 34; k++ {
+		start := k * 68
+		if s.DecodedLine <= start || s.DecodedLine-start > SERIAL_LINE_REPR_MAX {
+			continue
+		}
+		rem := s.DecodedLine - start
+		d := Derivative{
+			Serial: fmt.Sprintf("%s%s%s%c%c%c%s", s.Country, s.Year, week, AppleBase34Reverse[k],
+				AppleBase34Reverse[rem/34], AppleBase34Reverse[rem%34], s.Model),
+			Copy: k - rmin + 1,
+		}
+		d.Original = d.Serial == original
+		if d.Copy < opts.CopyMin || d.Copy > opts.CopyMax || (d.Original && opts.ExcludeOriginal) {
+			continue
+		}
+		if info, err := decodeSerial(d.Serial, false); err == nil {
+			d.Valid = info.Valid
+		}
+		if opts.OnlyValid && !d.Valid {
+			continue
+		}
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+// printDerivatives prints the derivatives of a user supplied serial to w
+// notes and warnings go to stderr in JSON mode so that w only gets valid JSON
+func printDerivatives(w io.Writer, stderr io.Writer, serial string, opts DerivOptions, asJSON bool) error {
+	notes := w
+	if asJSON {
+		notes = stderr
+	}
+	normalized := normalizeSerial(serial)
+	if normalized != serial {
+		fmt.Fprintf(notes, "NOTE: Serial %s normalized to %s\n", serial, normalized)
+	}
+	s, err := decodeSerial(normalized, false)
+	if err != nil {
+		return err
+	}
+	for _, msg := range s.Warnings {
+		fmt.Fprintf(notes, "WARN: %s\n", msg)
+	}
+	derivs := derivativeSerials(s, opts)
+	if asJSON {
+		data, err := json.MarshalIndent(derivs, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+	for _, d := range derivs {
+		fmt.Fprintf(w, "%s - copy %d\n", d.Serial, d.Copy)
+	}
+	return nil
+}
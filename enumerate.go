@@ -0,0 +1,179 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const ENUM_WILDCARD = '?'
+
+// matchPattern checks if value matches the pattern where ENUM_WILDCARD matches any symbol
+func matchPattern(pattern, value string) bool {
+	if len(pattern) != len(value) {
+		return false
+	}
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != ENUM_WILDCARD && pattern[i] != value[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// expandSymbol returns all the symbols of the alphabet matched by a single pattern symbol
+func expandSymbol(c byte, alphabet string) []string {
+	var ret []string
+	var seen string
+	for i := 0; i < len(alphabet); i++ {
+		// some alphabets repeat symbols (e.g. AppleWeekReverse)
+		if strings.IndexByte(seen, alphabet[i]) >= 0 {
+			continue
+		}
+		seen += alphabet[i : i+1]
+		if c == ENUM_WILDCARD || c == alphabet[i] {
+			ret = append(ret, alphabet[i:i+1])
+		}
+	}
+	return ret
+}
+
+// expandList returns all the values of the list matched by the pattern
+func expandList(pattern string, list []string) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	for _, v := range list {
+		if matchPattern(pattern, v) && !seen[v] {
+			seen[v] = true
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// knownModelCodes returns all the model codes with the given length
+func knownModelCodes(size int) []string {
+	var ret []string
+	for i := 0; i < len(AppleModelCode); i++ {
+		for j := 0; j < APPLE_MODEL_CODE_MAX && AppleModelCode[i][j] != ""; j++ {
+			if len(AppleModelCode[i][j]) == size {
+				ret = append(ret, AppleModelCode[i][j])
+			}
+		}
+	}
+	return ret
+}
+
+// enumerateSegments splits the pattern into the serial fields and expands each one
+// over the values that are valid for that field
+func enumerateSegments(pattern string) ([][]string, error) {
+	var segments [][]string
+	var countryLen, modelLen int
+	var year, week string
+	switch len(pattern) {
+	case SERIAL_NEW_LEN:
+		countryLen = COUNTRY_NEW_LEN
+		modelLen = MODEL_CODE_NEW_LEN
+		year = AppleYearReverse
+		// first entry is not a valid week symbol
+		week = AppleWeekReverse[1:]
+	case SERIAL_OLD_LEN:
+		countryLen = COUNTRY_OLD_LEN
+		modelLen = MODEL_CODE_OLD_LEN
+		year = "0123456789"
+		week = "0123456789"
+	default:
+		return nil, fmt.Errorf("Invalid pattern length, must be %d or %d", SERIAL_NEW_LEN, SERIAL_OLD_LEN)
+	}
+
+	if countryLen == COUNTRY_NEW_LEN {
+		segments = append(segments, expandList(pattern[:countryLen], AppleLocations))
+	} else {
+		segments = append(segments, expandList(pattern[:countryLen], AppleLegacyLocations))
+	}
+	pos := countryLen
+	segments = append(segments, expandSymbol(pattern[pos], year))
+	pos++
+	for ; pos < len(pattern)-modelLen-3; pos++ {
+		segments = append(segments, expandSymbol(pattern[pos], week))
+	}
+	for ; pos < len(pattern)-modelLen; pos++ {
+		segments = append(segments, expandSymbol(pattern[pos], AppleBase34Reverse))
+	}
+	segments = append(segments, expandList(pattern[pos:], knownModelCodes(modelLen)))
+
+	for i, seg := range segments {
+		if len(seg) == 0 {
+			return nil, fmt.Errorf("Pattern %s has no valid values for field %d", pattern, i)
+		}
+	}
+	return segments, nil
+}
+
+// enumerateSerials calls fn for every serial matched by the pattern that is considered valid
+// enumeration stops if fn returns false, fn can be nil if only the count is needed
+// returns the number of valid serials found
+func enumerateSerials(pattern string, fn func(Serial) bool) (int, error) {
+	segments, err := enumerateSegments(strings.ToUpper(pattern))
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	parts := make([]string, len(segments))
+	var walk func(i int) bool
+	walk = func(i int) bool {
+		if i == len(segments) {
+			s, err := decodeSerial(strings.Join(parts, ""), false)
+			if err != nil || !s.Valid || s.DecodedWeek < 0 {
+				return true
+			}
+			count++
+			if fn != nil {
+				return fn(s)
+			}
+			return true
+		}
+		for _, v := range segments[i] {
+			parts[i] = v
+			if !walk(i + 1) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(0)
+	return count, nil
+}
@@ -0,0 +1,270 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// default minimum number of members for a group to be reported as a cluster
+const FLEET_MIN_CLUSTER = 3
+
+// share of a single ROM prefix above which the prefix is reported
+const FLEET_ROM_PREFIX_SHARE = 0.25
+
+// a group of identities sharing some property
+type Cluster struct {
+	Key     string   `json:"key"`
+	Members []string `json:"members"`
+}
+
+// how concentrated the values of a field are
+type Spread struct {
+	Field    string  `json:"field"`
+	Distinct int     `json:"distinct"`
+	Top      string  `json:"top"`
+	TopShare float64 `json:"top_share"`
+}
+
+type FleetReport struct {
+	Total       int       `json:"total"`
+	Invalid     []string  `json:"invalid,omitempty"`
+	Spreads     []Spread  `json:"spreads"`
+	Lines       []Cluster `json:"lines,omitempty"`       // same production week and line
+	Derivatives []Cluster `json:"derivatives,omitempty"` // same decoded line with different copies
+	MLBBlocks   []Cluster `json:"mlb_blocks,omitempty"`  // same MLB block pattern
+	ROMPrefixes []Cluster `json:"rom_prefixes,omitempty"`
+	NonAppleROM []string  `json:"non_apple_rom,omitempty"`
+}
+
+// parseIdentityLine parses a line in the generator output formats or in the serial[,mlb[,rom]] format
+// --generate and the bulk generator label the fields but --generate-all prints product | serial | mlb
+func parseIdentityLine(line string) (Identity, error) {
+	if !strings.Contains(line, "|") {
+		return parseIdentity(line)
+	}
+	if !strings.Contains(line, "Serial:") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 2 || len(fields) > 4 {
+			return Identity{}, fmt.Errorf("Invalid identity %s, use product | serial | mlb", line)
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		// like the labeled format the MLB is not validated, analyzeFleet skips the invalid ones
+		id := Identity{ProductName: fields[0], Serial: normalizeSerial(fields[1])}
+		if len(fields) > 2 {
+			id.MLB = strings.ToUpper(fields[2])
+		}
+		if len(fields) > 3 {
+			id.ROM = strings.ToUpper(fields[3])
+		}
+		return id, nil
+	}
+	id := Identity{}
+	for i, field := range strings.Split(line, "|") {
+		field = strings.TrimSpace(field)
+		switch {
+		case strings.HasPrefix(field, "Serial:"):
+			id.Serial = normalizeSerial(strings.TrimPrefix(field, "Serial:"))
+		case strings.HasPrefix(field, "MLB:"):
+			id.MLB = strings.TrimSpace(strings.TrimPrefix(field, "MLB:"))
+		case strings.HasPrefix(field, "ROM:"):
+			id.ROM = strings.TrimSpace(strings.TrimPrefix(field, "ROM:"))
+		case i == 0:
+			id.ProductName = field
+		}
+	}
+	return id, nil
+}
+
+// readIdentities reads one identity per line, empty lines and # comments are skipped
+func readIdentities(r io.Reader) ([]Identity, error) {
+	var ids []Identity
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, err := parseIdentityLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, scanner.Err()
+}
+
+// groupClusters returns the groups with at least min members sorted by size
+func groupClusters(groups map[string][]string, min int) []Cluster {
+	var ret []Cluster
+	for k, v := range groups {
+		if len(v) >= min {
+			ret = append(ret, Cluster{Key: k, Members: v})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if len(ret[i].Members) != len(ret[j].Members) {
+			return len(ret[i].Members) > len(ret[j].Members)
+		}
+		return ret[i].Key < ret[j].Key
+	})
+	return ret
+}
+
+// spread returns how concentrated the values are
+func spread(field string, counts map[string]int, total int) Spread {
+	s := Spread{Field: field, Distinct: len(counts)}
+	for k, v := range counts {
+		share := float64(v) / float64(total)
+		if share > s.TopShare || (share == s.TopShare && k < s.Top) {
+			s.Top = k
+			s.TopShare = share
+		}
+	}
+	return s
+}
+
+// analyzeFleet looks for signs that the identities were generated by the same tool
+func analyzeFleet(ids []Identity, minCluster int) FleetReport {
+	report := FleetReport{Total: len(ids)}
+	if minCluster < 2 {
+		minCluster = 2
+	}
+
+	counts := map[string]map[string]int{}
+	count := func(field, value string) {
+		if counts[field] == nil {
+			counts[field] = make(map[string]int)
+		}
+		counts[field][value]++
+	}
+	lines := make(map[string][]string)
+	derivs := make(map[string][]string)
+	derivCopies := make(map[string]map[int]bool)
+	blocks := make(map[string][]string)
+	prefixes := make(map[string][]string)
+	roms := 0
+
+	for _, id := range ids {
+		s, err := decodeSerial(id.Serial, false)
+		if err != nil {
+			report.Invalid = append(report.Invalid, id.Serial)
+			continue
+		}
+		count("Country", s.Country)
+		count("Model code", s.Model)
+		week := fmt.Sprintf("%04d-W%02d", s.DecodedYear, s.DecodedWeek)
+		count("Week", week)
+
+		key := fmt.Sprintf("%s line %d", week, s.DecodedLine)
+		lines[key] = append(lines[key], id.Serial)
+		// derivatives share everything but the copy
+		key = fmt.Sprintf("%s %s line %d %s", s.Country, week, s.DecodedLine, s.Model)
+		derivs[key] = append(derivs[key], id.Serial)
+		if derivCopies[key] == nil {
+			derivCopies[key] = make(map[int]bool)
+		}
+		derivCopies[key][s.DecodedCopy] = true
+
+		if m, err := parseMLB(id.MLB); err == nil {
+			key = m.Block1 + "-" + m.Block2 + "-" + m.Block3
+			blocks[key] = append(blocks[key], id.MLB)
+			count("MLB board", m.Board)
+		}
+		rom := strings.ToUpper(strings.NewReplacer(":", "", "-", "").Replace(id.ROM))
+		if len(rom) == 12 {
+			roms++
+			prefix := rom[:6]
+			prefixes[prefix] = append(prefixes[prefix], rom)
+			if !isAppleROMPrefix(prefix) {
+				report.NonAppleROM = append(report.NonAppleROM, rom)
+			}
+		}
+	}
+
+	for _, field := range []string{"Country", "Week", "Model code", "MLB board"} {
+		if counts[field] != nil {
+			report.Spreads = append(report.Spreads, spread(field, counts[field], report.Total-len(report.Invalid)))
+		}
+	}
+	report.Lines = groupClusters(lines, minCluster)
+	// derivatives are suspicious even in pairs
+	for k := range derivs {
+		if len(derivCopies[k]) < 2 {
+			delete(derivs, k)
+		}
+	}
+	report.Derivatives = groupClusters(derivs, 2)
+	report.MLBBlocks = groupClusters(blocks, minCluster)
+	for k, v := range prefixes {
+		if len(v) < minCluster || float64(len(v)) < FLEET_ROM_PREFIX_SHARE*float64(roms) {
+			delete(prefixes, k)
+		}
+	}
+	report.ROMPrefixes = groupClusters(prefixes, minCluster)
+	return report
+}
+
+func printClusters(title string, clusters []Cluster) {
+	if len(clusters) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for _, c := range clusters {
+		fmt.Printf(" - %s (%d): %s\n", c.Key, len(c.Members), strings.Join(c.Members, ", "))
+	}
+}
+
+func (r *FleetReport) Print() {
+	fmt.Printf("%14s: %d\n", "Identities", r.Total)
+	if len(r.Invalid) > 0 {
+		fmt.Printf("%14s: %d (%s)\n", "Invalid", len(r.Invalid), strings.Join(r.Invalid, ", "))
+	}
+	for _, s := range r.Spreads {
+		fmt.Printf("%14s: %d distinct, top %s (%.1f%%)\n", s.Field, s.Distinct, s.Top, s.TopShare*100)
+	}
+	printClusters("Serials sharing production week and line", r.Lines)
+	printClusters("Derivative serials (same line, different copy)", r.Derivatives)
+	printClusters("MLBs sharing block pattern", r.MLBBlocks)
+	printClusters("Concentrated ROM prefixes", r.ROMPrefixes)
+	if len(r.NonAppleROM) > 0 {
+		fmt.Printf("\nROMs without Apple prefix: %s\n", strings.Join(r.NonAppleROM, ", "))
+	}
+}
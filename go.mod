@@ -2,4 +2,7 @@ module github.com/gdbinit/SMBIOSKeygen
 
 go 1.19
 
-require github.com/google/uuid v1.3.0
+require (
+	github.com/google/uuid v1.3.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,132 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+// lookup tables built from the model database so decoding doesn't need to scan the tables
+// buildIndexes must be called again if the tables are modified
+
+// model code to all the model indexes using it, in table order
+var modelCodeIndex map[string][]int
+
+// product code to its AppleModelDesc description
+var productDescIndex map[string]string
+
+// location code to its AppleLocations and AppleLegacyLocations index
+var locationIndex map[string]int
+var legacyLocationIndex map[string]int
+
+// MLBBlock1, MLBBlock2 and MLBBlock3 without the duplicate entries
+var mlbBlocks [3][]string
+
+// symbol values for each conversion table, -1 if the symbol is not valid
+var base34Values [256]int
+var yearValues [256]int
+var weekValues [256]int
+var weekAddValues [256]int
+
+func init() {
+	buildIndexes()
+}
+
+// buildAlphaTable converts a conversion table into a lookup table with the same results as alphaToValue
+func buildAlphaTable(conv []int, blacklist string) [256]int {
+	var table [256]int
+	for i := range table {
+		table[i] = -1
+	}
+	for c := 'A'; c <= 'Z'; c++ {
+		table[c] = alphaToValue(byte(c), conv, blacklist)
+	}
+	return table
+}
+
+func buildIndexes() {
+	modelCodeIndex = make(map[string][]int)
+	for i := 0; i < len(AppleModelCode); i++ {
+		for j := 0; j < APPLE_MODEL_CODE_MAX && AppleModelCode[i][j] != ""; j++ {
+			code := AppleModelCode[i][j]
+			modelCodeIndex[code] = append(modelCodeIndex[code], i)
+		}
+	}
+
+	productDescIndex = make(map[string]string)
+	for _, desc := range AppleModelDesc {
+		// first entry wins as in the original linear search
+		if _, ok := productDescIndex[desc.code]; !ok {
+			productDescIndex[desc.code] = desc.name
+		}
+	}
+
+	locationIndex = make(map[string]int)
+	for i, loc := range AppleLocations {
+		if _, ok := locationIndex[loc]; !ok {
+			locationIndex[loc] = i
+		}
+	}
+	legacyLocationIndex = make(map[string]int)
+	for i, loc := range AppleLegacyLocations {
+		if _, ok := legacyLocationIndex[loc]; !ok {
+			legacyLocationIndex[loc] = i
+		}
+	}
+
+	for i, block := range [][]string{MLBBlock1, MLBBlock2, MLBBlock3} {
+		mlbBlocks[i] = nil
+		seen := make(map[string]bool)
+		for _, b := range block {
+			if !seen[b] {
+				seen[b] = true
+				mlbBlocks[i] = append(mlbBlocks[i], b)
+			}
+		}
+	}
+
+	base34Values = buildAlphaTable(AppleTblBase34, AppleBase34Blacklist)
+	for c := '0'; c <= '9'; c++ {
+		base34Values[c] = int(c - '0')
+	}
+	yearValues = buildAlphaTable(AppleTblYear, AppleYearBlacklist)
+	weekValues = buildAlphaTable(AppleTblWeek, AppleWeekBlacklist)
+	weekAddValues = buildAlphaTable(AppleTblWeekAdd, "")
+}
+
+// lookupModelCode returns the model index of the model code or -1 if unknown
+// shared codes resolve to the last model using it, same as the original table scan
+func lookupModelCode(code string) int {
+	indexes := modelCodeIndex[code]
+	if len(indexes) == 0 {
+		return -1
+	}
+	return indexes[len(indexes)-1]
+}
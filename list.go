@@ -0,0 +1,196 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// filters of --list, empty values match every model
+type ListFilter struct {
+	Family    string // product name without the version like MacBookPro
+	Year      int    // -1 for any year
+	Code      string // model code
+	BoardCode string
+	Search    string // text in the product descriptions or in the product name
+	Supports  []int  // macOS version
+}
+
+// a product code of the model whose description matched the search
+type CodeMatch struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+type ListedModel struct {
+	Model      string        `json:"model"`
+	Index      int           `json:"index"`
+	Years      []uint32      `json:"years"`
+	Serial     string        `json:"base_serial"`
+	ModelCodes []string      `json:"model_codes"`
+	BoardCodes []string      `json:"board_codes"`
+	CPU        CPUInfo       `json:"cpu"`
+	MacOS      *MacOSSupport `json:"macos,omitempty"`
+	SMBIOS     *SMBIOSInfo   `json:"smbios,omitempty"`
+	Matches    []CodeMatch   `json:"matches,omitempty"`
+}
+
+// modelFamily returns the product name without the version, MacBookPro15,1 is MacBookPro
+func modelFamily(name string) string {
+	return strings.TrimRight(name, "0123456789,")
+}
+
+// Empty checks if the filter matches every model
+func (f *ListFilter) Empty() bool {
+	return f.Family == "" && f.Year == -1 && f.Code == "" && f.BoardCode == "" && f.Search == "" && f.Supports == nil
+}
+
+// listModels returns the models matching all the filters
+func listModels(f ListFilter) []ListedModel {
+	code := strings.ToUpper(f.Code)
+	board := strings.ToUpper(f.BoardCode)
+	search := strings.ToLower(f.Search)
+	// product codes with a matching description
+	searchCodes := make(map[string]string)
+	if search != "" {
+		for _, d := range AppleModelDesc {
+			if _, ok := searchCodes[d.code]; !ok && strings.Contains(strings.ToLower(d.name), search) {
+				searchCodes[d.code] = d.name
+			}
+		}
+	}
+
+	models := []ListedModel{}
+	for i := range ApplePlatformData {
+		model := AppleModel(i)
+		name := ApplePlatformData[i].productName
+		if f.Family != "" && !strings.EqualFold(modelFamily(name), f.Family) {
+			continue
+		}
+		if f.Year != -1 && !modelHasYear(model, f.Year) {
+			continue
+		}
+		if code != "" && findCode(AppleModelCode[i][:], code) < 0 {
+			continue
+		}
+		if board != "" && findCode(AppleBoardCode[i][:], board) < 0 {
+			continue
+		}
+		macos := modelMacOS(model)
+		if f.Supports != nil {
+			if ok, _ := macos.Supports(f.Supports); !ok {
+				continue
+			}
+		}
+
+		m := ListedModel{Model: name, Index: i, Serial: ApplePlatformData[i].serialNumber, CPU: modelCPU(model)}
+		for j := 0; j < APPLE_MODEL_YEAR_MAX && AppleModelYear[i][j] > 0; j++ {
+			m.Years = append(m.Years, AppleModelYear[i][j])
+		}
+		for j := 0; j < APPLE_MODEL_CODE_MAX && AppleModelCode[i][j] != ""; j++ {
+			c := AppleModelCode[i][j]
+			m.ModelCodes = append(m.ModelCodes, c)
+			if desc, ok := searchCodes[c]; ok {
+				m.Matches = append(m.Matches, CodeMatch{Code: c, Name: desc})
+			} else if c == code && productDescIndex[c] != "" {
+				m.Matches = append(m.Matches, CodeMatch{Code: c, Name: productDescIndex[c]})
+			}
+		}
+		for j := 0; j < APPLE_BOARD_CODE_MAX && AppleBoardCode[i][j] != ""; j++ {
+			m.BoardCodes = append(m.BoardCodes, AppleBoardCode[i][j])
+		}
+		if search != "" && len(m.Matches) == 0 && !strings.Contains(strings.ToLower(name), search) {
+			continue
+		}
+		if macos.Known() {
+			m.MacOS = &macos
+		}
+		if smbios := modelSMBIOS(model); !smbios.Empty() {
+			m.SMBIOS = &smbios
+		}
+		models = append(models, m)
+	}
+	return models
+}
+
+// yearRange returns the years as 2019 or 2019-2020
+func yearRange(years []uint32) string {
+	switch len(years) {
+	case 0:
+		return "-"
+	case 1:
+		return fmt.Sprint(years[0])
+	}
+	return fmt.Sprintf("%d-%d", years[0], years[len(years)-1])
+}
+
+func (m *ListedModel) Print() {
+	fmt.Printf("%14s: %s\n", "Model", m.Model)
+	fmt.Printf("%14s: %d\n", "Model Index", m.Index)
+	fmt.Printf("%14s: %s\n", "Prod years", joinYears(m.Years))
+	fmt.Printf("%14s: %s\n", "Base Serial", m.Serial)
+	fmt.Printf("%14s: %s\n", "Model codes", strings.Join(m.ModelCodes, ", "))
+	fmt.Printf("%14s: %s\n", "Board codes", strings.Join(m.BoardCodes, ", "))
+	if m.CPU.Arch != "" {
+		fmt.Printf("%14s: %s (%s)\n", "CPU", m.CPU.Name, m.CPU.Arch)
+	} else {
+		fmt.Printf("%14s: %s\n", "CPU", m.CPU.Name)
+	}
+	if m.MacOS != nil {
+		fmt.Printf("%14s: %s\n", "macOS", m.MacOS.String())
+	}
+	if m.SMBIOS != nil {
+		m.SMBIOS.Print()
+	}
+	for i, match := range m.Matches {
+		label := ""
+		if i == 0 {
+			label = "Matches"
+		}
+		fmt.Printf("%14s: %s - %s\n", label, match.Code, match.Name)
+	}
+}
+
+// printModelTable prints one line per model
+func printModelTable(models []ListedModel) {
+	fmt.Printf("%5s  %-15s %-10s %-13s %5s %5s  %s\n", "Index", "Model", "Years", "Base Serial", "Codes", "Board", "CPU")
+	for _, m := range models {
+		fmt.Printf("%5d  %-15s %-10s %-13s %5d %5d  %s\n", m.Index, m.Model, yearRange(m.Years), m.Serial,
+			len(m.ModelCodes), len(m.BoardCodes), m.CPU.Name)
+		for _, match := range m.Matches {
+			fmt.Printf("%7s%s - %s\n", "", match.Code, match.Name)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// range of macOS versions supported by a model from the AppleModels database
+// the generated AppleMacOSSupport table is parallel to ApplePlatformData
+// an empty Max means the model is still supported by the latest release
+type MacOSSupport struct {
+	Min string `json:"min,omitempty" yaml:"min"`
+	Max string `json:"max,omitempty" yaml:"max"`
+}
+
+// parseMacOSVersion parses versions like 10.15.7 or 13
+func parseMacOSVersion(version string) ([]int, error) {
+	parts := strings.Split(strings.TrimSpace(version), ".")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("Invalid macOS version %s", version)
+	}
+	ret := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("Invalid macOS version %s", version)
+		}
+		ret[i] = n
+	}
+	return ret, nil
+}
+
+// compareMacOSVersions compares a and b only up to the precision of a
+// so 12 is equal to 12.7.1 and 10.13 is equal to 10.13.2
+func compareMacOSVersions(a, b []int) int {
+	for i := range a {
+		v := 0
+		if i < len(b) {
+			v = b[i]
+		}
+		if a[i] != v {
+			if a[i] < v {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Known checks if any support information is available
+func (m *MacOSSupport) Known() bool {
+	return m.Min != "" || m.Max != ""
+}
+
+// Supports checks if the version is in the supported range, the second value is false if the range is unknown
+func (m *MacOSSupport) Supports(version []int) (bool, bool) {
+	if !m.Known() {
+		return false, false
+	}
+	if m.Min != "" {
+		min, err := parseMacOSVersion(m.Min)
+		if err != nil || compareMacOSVersions(version, min) < 0 {
+			return false, true
+		}
+	}
+	// a maximum of 12 includes 12.6 so compare with its own precision
+	if m.Max != "" {
+		max, err := parseMacOSVersion(m.Max)
+		if err != nil || compareMacOSVersions(max, version) < 0 {
+			return false, true
+		}
+	}
+	return true, true
+}
+
+func (m *MacOSSupport) String() string {
+	if !m.Known() {
+		return "Unknown"
+	}
+	min, max := m.Min, m.Max
+	if min == "" {
+		min = "?"
+	}
+	if max == "" {
+		max = "latest"
+	}
+	return min + " - " + max
+}
+
+// modelMacOS returns the supported macOS versions of the model
+func modelMacOS(model AppleModel) MacOSSupport {
+	if int(model) >= len(AppleMacOSSupport) {
+		return MacOSSupport{}
+	}
+	return AppleMacOSSupport[model]
+}
+
+// hasMacOSSupport checks if any model has known macOS support information
+func hasMacOSSupport() bool {
+	for i := range AppleMacOSSupport {
+		if AppleMacOSSupport[i].Known() {
+			return true
+		}
+	}
+	return false
+}
+
+// validateMacOSSupport checks that the versions can be parsed and are in order
+func validateMacOSSupport(m MacOSSupport) error {
+	var min, max []int
+	var err error
+	if m.Min != "" {
+		if min, err = parseMacOSVersion(m.Min); err != nil {
+			return err
+		}
+	}
+	if m.Max != "" {
+		if max, err = parseMacOSVersion(m.Max); err != nil {
+			return err
+		}
+	}
+	// a maximum of 13 includes 13.2 so compare with its own precision
+	if min != nil && max != nil && compareMacOSVersions(max, min) < 0 {
+		return fmt.Errorf("Minimum macOS %s is newer than maximum %s", m.Min, m.Max)
+	}
+	return nil
+}
+
+// macOSWarning explains why the model may not run the target macOS version, empty if it is supported
+func macOSWarning(model AppleModel, target string, version []int) string {
+	support := modelMacOS(model)
+	name := ApplePlatformData[model].productName
+	supported, known := support.Supports(version)
+	switch {
+	case !known:
+		return fmt.Sprintf("macOS support of %s is unknown, can't check macOS %s", name, target)
+	case supported:
+		return ""
+	case support.Max != "":
+		return fmt.Sprintf("%s is not supported by macOS %s (supported %s)", name, target, support.String())
+	default:
+		return fmt.Sprintf("%s requires macOS %s or newer", name, support.Min)
+	}
+}
@@ -41,14 +41,17 @@
 package main
 
 import (
+	"bufio"
 	crand "crypto/rand"
 	"encoding/binary"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"math/rand"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -78,6 +81,15 @@ const (
 	COUNTRY_OLD_LEN      = 2
 	COUNTRY_NEW_LEN      = 3
 	MLB_MAX_SIZE         = 32
+	MLB_OLD_LEN          = 13
+	MLB_NEW_LEN          = 17
+)
+
+// how model and board codes are chosen from the model tables
+const (
+	CODE_PICK_FIRST    = iota // always the first entry, original macserial behavior
+	CODE_PICK_UNIFORM         // any entry with the same probability
+	CODE_PICK_WEIGHTED        // any entry but first entries are more likely
 )
 
 const (
@@ -124,22 +136,31 @@ type Serial struct {
 	DecodedLine int
 	DecodedCopy int
 	Valid       bool
-	Legacy      bool // true if legacy serial number
+	Legacy      bool     // true if legacy serial number
+	Warnings    []string // problems found while decoding
 	// internal data
 	index        int // the model index
 	countryIndex int
+	boardCode    string     // board code to use in the MLB, model default if empty
+	rnd          *rand.Rand // random source for the MLB, the secure generator if nil
 }
 
 // all the possible tunning parameters
 // Index or ModelCode are mandatory but mutally exclusive
 type Params struct {
-	Index     int    // model index (can be found using -l option)
-	Year      int    // production year
-	Week      int    // production week
-	Country   string // country code
-	ModelCode string // the 3 or 4 digit model code (can be found using -l option)
-	Line      int    // the production line
-	Copy      int    //
+	Index       int            // model index (can be found using -l option)
+	Year        int            // production year
+	Week        int            // production week
+	Country     string         // country code
+	ModelCode   string         // the 3 or 4 digit model code (can be found using -l option)
+	Line        int            // the production line
+	Copy        int            //
+	From        ProductionWeek // production date range start, Year and Week are ignored if set
+	To          ProductionWeek // production date range end
+	Rand        *rand.Rand     // random source, the secure generator if nil
+	CodePick    int            // how to choose model and board codes (CODE_PICK_*)
+	CountryPick int            // how to choose the country if not set (COUNTRY_PICK_*)
+	BoardCode   string         // the board code used for the MLB (must belong to the model)
 }
 
 var rnd *rand.Rand
@@ -164,21 +185,33 @@ func init() {
 	rnd = rand.New(src)
 }
 
-func pseudoRandom() int {
-	return rnd.Int()
+// pseudoRandom returns a random number from r or from the secure generator if r is nil
+func pseudoRandom(r *rand.Rand) int {
+	if r == nil {
+		r = rnd
+	}
+	return r.Int()
 }
 
 // pseudoRandomBetween returns a random number between the half-open interval
 // make sure that b > a otherwise it panics
-func pseudoRandomBetween(a, b uint32) int {
+func pseudoRandomBetween(r *rand.Rand, a, b uint32) int {
+	if r == nil {
+		r = rnd
+	}
 	// no b<a check, it panics if n <= 0 ;-)
-	return rnd.Intn(int(b-a)) + int(a)
+	return r.Intn(int(b-a)) + int(a)
 }
 
 // generateROM generates a MAC address based on Apple prefixes
 func generateROM() string {
-	prefix := AppleRomPrefix[pseudoRandom()%len(AppleRomPrefix)]
-	mac := fmt.Sprintf("%s%02X%02X%02X", prefix, pseudoRandomBetween(0, 256), pseudoRandomBetween(0, 256), pseudoRandomBetween(0, 256))
+	return randomROM(nil)
+}
+
+// randomROM generates a MAC address based on Apple prefixes using the random source r
+func randomROM(r *rand.Rand) string {
+	prefix := AppleRomPrefix[pseudoRandom(r)%len(AppleRomPrefix)]
+	mac := fmt.Sprintf("%s%02X%02X%02X", prefix, pseudoRandomBetween(r, 0, 256), pseudoRandomBetween(r, 0, 256), pseudoRandomBetween(r, 0, 256))
 	return mac
 }
 
@@ -199,14 +232,8 @@ func alphaToValue(c byte, conv []int, blacklist string) int {
 
 // This is modified base34 used by Apple with I and O excluded.
 func base34ToValue(c byte, mul int) int {
-	if c >= '0' && c <= '9' {
-		return int((c - '0')) * mul
-	}
-	if c >= 'A' && c <= 'Z' {
-		tmp := alphaToValue(c, AppleTblBase34, AppleBase34Blacklist)
-		if tmp >= 0 {
-			return tmp * mul
-		}
+	if tmp := base34Values[c]; tmp >= 0 {
+		return tmp * mul
 	}
 	return -1
 }
@@ -271,6 +298,11 @@ func VerifyMLBChecksum(mlb string) bool {
 }
 
 func getProductionYear(model AppleModel, print bool) uint32 {
+	return productionYear(model, print, nil)
+}
+
+// productionYear does the real work for getProductionYear using the random source r
+func productionYear(model AppleModel, print bool, r *rand.Rand) uint32 {
 	var num uint32
 
 	for num = 0; num < APPLE_MODEL_YEAR_MAX && AppleModelYear[model][num] > 0; num++ {
@@ -288,7 +320,7 @@ func getProductionYear(model AppleModel, print bool) uint32 {
 	}
 
 	// XXX: improve this random? we just need a tiny number
-	return AppleModelYear[model][uint32(pseudoRandom())%num]
+	return AppleModelYear[model][uint32(pseudoRandom(r))%num]
 }
 
 func getModelCode(model AppleModel, print bool) string {
@@ -319,9 +351,76 @@ func getBoardCode(model AppleModel, print bool) string {
 	return AppleBoardCode[model][0]
 }
 
+// pickCode chooses one of the codes according to the CODE_PICK_* mode using the random source r
+// codes are the model table entries so they end at the first empty string
+func pickCode(codes []string, mode int, r *rand.Rand) string {
+	n := 0
+	for n < len(codes) && codes[n] != "" {
+		n++
+	}
+	if n <= 1 || mode == CODE_PICK_FIRST {
+		return codes[0]
+	}
+	if mode == CODE_PICK_UNIFORM {
+		return codes[pseudoRandom(r)%n]
+	}
+	// weight of each entry is n - i, first entries are the most common ones
+	w := pseudoRandom(r) % (n * (n + 1) / 2)
+	for i := 0; i < n; i++ {
+		w -= n - i
+		if w < 0 {
+			return codes[i]
+		}
+	}
+	return codes[0]
+}
+
+// pickModelCode chooses one of the model codes of the model
+func pickModelCode(model AppleModel, mode int, r *rand.Rand) string {
+	return pickCode(AppleModelCode[model][:], mode, r)
+}
+
+// pickBoardCode chooses one of the board codes of the model
+func pickBoardCode(model AppleModel, mode int, r *rand.Rand) string {
+	return pickCode(AppleBoardCode[model][:], mode, r)
+}
+
+// findCode returns the index of code in the model table entries or -1 if not found
+func findCode(codes []string, code string) int {
+	for i := 0; i < len(codes) && codes[i] != ""; i++ {
+		if codes[i] == code {
+			return i
+		}
+	}
+	return -1
+}
+
+// modelHasYear checks if the year is one of the production years of the model
+func modelHasYear(model AppleModel, year int) bool {
+	for i := 0; i < APPLE_MODEL_YEAR_MAX && AppleModelYear[model][i] > 0; i++ {
+		if int(AppleModelYear[model][i]) == year {
+			return true
+		}
+	}
+	return false
+}
+
 // parseSerial retrieves the information about a serial number
 func parseSerial(serial string) (Serial, error) {
+	return decodeSerial(serial, true)
+}
+
+// decodeSerial does the real work for parseSerial
+// warnings are always collected in the Warnings field and only printed if verbose is set
+func decodeSerial(serial string, verbose bool) (Serial, error) {
 	info := Serial{}
+	warn := func(format string, a ...interface{}) {
+		msg := fmt.Sprintf(format, a...)
+		info.Warnings = append(info.Warnings, msg)
+		if verbose {
+			fmt.Printf("WARN: %s\n", msg)
+		}
+	}
 	// Verify length.
 	serial_len := len(serial)
 	if serial_len != SERIAL_OLD_LEN && serial_len != SERIAL_NEW_LEN {
@@ -339,7 +438,7 @@ func parseSerial(serial string) (Serial, error) {
 	for i := 0; i < serial_len; i++ {
 		if !((serial[i] >= 'A' && serial[i] <= 'Z' && serial[i] != 'O' && serial[i] != 'I') ||
 			(serial[i] >= '0' && serial[i] <= '9')) {
-			fmt.Printf("WARN: Invalid symbol '%c' in serial!\n", serial[i])
+			warn("Invalid symbol '%c' in serial!", serial[i])
 			info.Valid = false
 		}
 	}
@@ -354,29 +453,13 @@ func parseSerial(serial string) (Serial, error) {
 		serialModel = serial[serial_len-MODEL_CODE_OLD_LEN:]
 	}
 	// Start with looking up the model.
-	info.index = -1
-	for i := 0; i < len(AppleModelCode); i++ {
-		for j := 0; j < APPLE_MODEL_CODE_MAX; j++ {
-			code := AppleModelCode[i][j]
-			if code == "" {
-				break
-			}
-			if code == serialModel {
-				info.Model = code
-				info.index = i
-				break
-			}
-		}
+	info.index = lookupModelCode(serialModel)
+	if info.index >= 0 {
+		info.Model = serialModel
 	}
 
 	// Also lookup apple model.
-	for i := 0; i < len(AppleModelDesc); i++ {
-		code := AppleModelDesc[i].code
-		if code == serialModel {
-			info.ModelDesc = AppleModelDesc[i].name
-			break
-		}
-	}
+	info.ModelDesc = productDescIndex[serialModel]
 
 	// Fallback to possibly valid values if model is unknown.
 	// XXX: does this makes sense???
@@ -396,23 +479,17 @@ func parseSerial(serial string) (Serial, error) {
 	if serial_len == SERIAL_NEW_LEN {
 		info.Country = serial[:COUNTRY_NEW_LEN]
 		// serial += COUNTRY_NEW_LEN;
-		for i := 0; i < len(AppleLocations); i++ {
-			if info.Country == AppleLocations[i] {
-				info.countryIndex = i
-				info.CountryDesc = AppleLocationNames[i]
-				break
-			}
+		if i, ok := locationIndex[info.Country]; ok {
+			info.countryIndex = i
+			info.CountryDesc = AppleLocationNames[i]
 		}
 	} else {
 		info.Legacy = true
 		info.Country = serial[:COUNTRY_OLD_LEN]
 		// serial += COUNTRY_OLD_LEN;
-		for i := 0; i < len(AppleLegacyLocations); i++ {
-			if info.Country == AppleLegacyLocations[i] {
-				info.countryIndex = i
-				info.CountryDesc = AppleLegacyLocationNames[i]
-				break
-			}
+		if i, ok := legacyLocationIndex[info.Country]; ok {
+			info.countryIndex = i
+			info.CountryDesc = AppleLegacyLocationNames[i]
 		}
 	}
 
@@ -422,7 +499,7 @@ func parseSerial(serial string) (Serial, error) {
 		info.Year[0] = serial[COUNTRY_NEW_LEN]
 		info.Week[0] = serial[COUNTRY_NEW_LEN+1]
 		// New encoding started in 2010.
-		info.DecodedYear = alphaToValue(info.Year[0], AppleTblYear, AppleYearBlacklist)
+		info.DecodedYear = yearValues[info.Year[0]]
 		// Since year can be encoded ambiguously, check the model code for 2010/2020 difference.
 		// Old check relies on first letter of model to be greater than or equal to H, which breaks compatibility with iMac20,2 (=0).
 		// Added logic checks provided model years `AppleModelYear` first year greater than or equal to 2020.
@@ -432,22 +509,22 @@ func parseSerial(serial string) (Serial, error) {
 		} else if info.DecodedYear >= 0 {
 			info.DecodedYear += 2010
 		} else {
-			fmt.Printf("WARN: Invalid year symbol '%c'!\n", info.Year[0])
+			warn("Invalid year symbol '%c'!", info.Year[0])
 			info.Valid = false
 		}
 
 		if info.Week[0] > '0' && info.Week[0] <= '9' {
 			info.DecodedWeek = int(info.Week[0] - '0')
 		} else {
-			info.DecodedWeek = alphaToValue(info.Week[0], AppleTblWeek, AppleWeekBlacklist)
+			info.DecodedWeek = weekValues[info.Week[0]]
 		}
 
 		if info.DecodedWeek > 0 {
 			if info.DecodedYear > 0 {
-				info.DecodedWeek += alphaToValue(info.Year[0], AppleTblWeekAdd, "")
+				info.DecodedWeek += weekAddValues[info.Year[0]]
 			}
 		} else {
-			fmt.Printf("WARN: Invalid week symbol '%c'!\n", info.Week[0])
+			warn("Invalid week symbol '%c'!", info.Week[0])
 			info.Valid = false
 		}
 	} else {
@@ -462,7 +539,7 @@ func parseSerial(serial string) (Serial, error) {
 			info.DecodedYear = 2000 + int(info.Year[0]-'0')
 		} else {
 			info.DecodedYear = -1
-			fmt.Printf("WARN: Invalid year symbol '%c'!\n", info.Year[0])
+			warn("Invalid year symbol '%c'!", info.Year[0])
 			info.Valid = false
 		}
 
@@ -475,7 +552,7 @@ func parseSerial(serial string) (Serial, error) {
 				}
 			} else {
 				info.DecodedWeek = -1
-				fmt.Printf("WARN: Invalid week symbol '%c'!\n", info.Week[i])
+				warn("Invalid week symbol '%c'!", info.Week[i])
 				info.Valid = false
 				break
 			}
@@ -483,19 +560,13 @@ func parseSerial(serial string) (Serial, error) {
 	}
 
 	if info.DecodedWeek < SERIAL_WEEK_MIN || info.DecodedWeek > SERIAL_WEEK_MAX {
-		fmt.Printf("WARN: Decoded week %d is out of valid range [%d, %d]!\n", info.DecodedWeek, SERIAL_WEEK_MIN, SERIAL_WEEK_MAX)
+		warn("Decoded week %d is out of valid range [%d, %d]!", info.DecodedWeek, SERIAL_WEEK_MIN, SERIAL_WEEK_MAX)
 		info.DecodedWeek = -1
 	}
 
 	if info.DecodedYear > 0 && info.index >= 0 {
-		found := false
-		for i := 0; !found && i < APPLE_MODEL_YEAR_MAX && AppleModelYear[info.index][i] > 0; i++ {
-			if int(AppleModelYear[info.index][i]) == info.DecodedYear {
-				found = true
-			}
-		}
-		if !found {
-			fmt.Printf("WARN: Invalid year %d for model %s\n", info.DecodedYear, ApplePlatformData[info.index].productName)
+		if !modelHasYear(AppleModel(info.index), info.DecodedYear) {
+			warn("Invalid year %d for model %s", info.DecodedYear, ApplePlatformData[info.index].productName)
 			info.Valid = false
 		}
 	}
@@ -529,7 +600,7 @@ func parseSerial(serial string) (Serial, error) {
 		if tmp >= 0 {
 			info.DecodedLine += tmp
 		} else {
-			fmt.Printf("WARN: Invalid line symbol '%c'!\n", info.Line[i])
+			warn("Invalid line symbol '%c'!", info.Line[i])
 			info.Valid = false
 			break
 		}
@@ -540,7 +611,9 @@ func parseSerial(serial string) (Serial, error) {
 		info.DecodedCopy = base34ToValue(info.Line[0], 1) - lineToRmin(info.DecodedLine)
 	}
 
-	info.ProductName = ApplePlatformData[info.index].productName
+	if info.index >= 0 {
+		info.ProductName = ApplePlatformData[info.index].productName
+	}
 
 	return info, nil
 }
@@ -555,7 +628,7 @@ func generateSerial(param Params) (Serial, error) {
 
 	var model string
 	if param.ModelCode == "" {
-		model = getModelCode(AppleModel(param.Index), false)
+		model = pickModelCode(AppleModel(param.Index), param.CodePick, param.Rand)
 	} else {
 		// XXX: validate the 3 digit model code
 		model = param.ModelCode
@@ -571,20 +644,24 @@ func generateSerial(param Params) (Serial, error) {
 		} else {
 			country_len = COUNTRY_OLD_LEN
 		}
-		if param.Index < 0 {
-			if country_len == COUNTRY_OLD_LEN {
-				country = AppleLegacyLocations[0]
-			} else {
-				country = AppleLocations[0]
-			}
-		} else {
-			// extract it from a legit serial from internal database
-			country = ApplePlatformData[param.Index].serialNumber[:country_len]
-		}
+		// without a model index only the format is known
+		country = pickCountry(param.Index, country_len, param.CountryPick, param.Rand)
+	} else if (country_len == COUNTRY_NEW_LEN) != (len(model) == MODEL_CODE_NEW_LEN) {
+		return Serial{}, fmt.Errorf("Country location %s does not match model code %s format", country, model)
 	}
 
 	year := param.Year
-	if param.Year < 0 {
+	week := param.Week
+	if param.From.Year > 0 {
+		pw, err := pickProductionWeek(param.Index, len(model) != MODEL_CODE_NEW_LEN, param.From, param.To, param.Rand)
+		if err != nil {
+			return Serial{}, err
+		}
+		year = pw.Year
+		week = pw.Week
+	}
+
+	if year < 0 {
 		// XXX: this enters in conflict with ModelCode
 		if param.Index < 0 {
 			if country_len == COUNTRY_OLD_LEN {
@@ -593,14 +670,13 @@ func generateSerial(param Params) (Serial, error) {
 				year = SERIAL_YEAR_NEW_MID
 			}
 		} else {
-			year = int(getProductionYear(AppleModel(param.Index), false))
+			year = int(productionYear(AppleModel(param.Index), false, param.Rand))
 		}
 	}
 
-	week := param.Week
 	// Last week is too rare to care
-	if param.Week < 0 {
-		week = pseudoRandomBetween(SERIAL_WEEK_MIN, SERIAL_WEEK_MAX-1)
+	if week < 0 {
+		week = pseudoRandomBetween(param.Rand, SERIAL_WEEK_MIN, SERIAL_WEEK_MAX-1)
 	}
 
 	var yearData [1]byte
@@ -639,7 +715,7 @@ func generateSerial(param Params) (Serial, error) {
 
 	line := param.Line
 	if param.Line < 0 {
-		line = pseudoRandomBetween(SERIAL_LINE_MIN, SERIAL_LINE_MAX)
+		line = pseudoRandomBetween(param.Rand, SERIAL_LINE_MIN, SERIAL_LINE_MAX)
 	}
 
 	rmin := lineToRmin(line)
@@ -663,6 +739,12 @@ func generateSerial(param Params) (Serial, error) {
 	if err != nil {
 		return s, err
 	}
+	s.rnd = param.Rand
+	if param.BoardCode != "" {
+		s.boardCode = param.BoardCode
+	} else if s.index >= 0 {
+		s.boardCode = pickBoardCode(AppleModel(s.index), param.CodePick, param.Rand)
+	}
 	return s, nil
 }
 
@@ -704,103 +786,250 @@ func (s *Serial) Print() {
 	}
 }
 
-// generates a MLB from the serial number
-func (s *Serial) MLB() string {
-	// This is a direct reverse from CCC, rework it later...
-	if s.index < 0 {
-		fmt.Printf("WARN: Unknown model, assuming default!\n")
-		s.index = APPLE_MODEL_MAX - 1
+// board returns the board code used for the MLB
+func (s *Serial) board() string {
+	if s.boardCode != "" {
+		return s.boardCode
 	}
-	for {
-		year := uint32(0)
-		week := uint32(0)
+	return getBoardCode(AppleModel(s.index), false)
+}
 
-		legacy := false
-		if len(s.Country) == COUNTRY_OLD_LEN {
-			legacy = true
-		}
+// mlbAlphabet is the base34 alphabet used by the MLB checksum
+const mlbAlphabet = "0123456789ABCDEFGHJKLMNPQRSTUVWXYZ"
 
-		if legacy {
-			year = uint32(s.Year[0] - '0')
-			week = uint32(s.Week[0]-'0')*10 + uint32(s.Week[1]-'0')
+// mlbChecksumPart returns the checksum contribution of str placed at offset of a MLB with size length
+// the checksum is a weighted sum so the contributions of each block can be computed separately
+// symbols outside the alphabet are ignored as in VerifyMLBChecksum
+func mlbChecksumPart(str string, offset int, size int) int {
+	checksum := 0
+	for i := 0; i < len(str); i++ {
+		j := strings.IndexByte(mlbAlphabet, str[i])
+		if j < 0 {
+			continue
+		}
+		if ((offset + i) & 1) == (size & 1) {
+			checksum += 3 * j
 		} else {
-			syear := s.Year[0]
-			sweek := s.Week[0]
-
-			srcyear := "CDFGHJKLMNPQRSTVWXYZ"
-			dstyear := "00112233445566778899"
-			for i := 0; i < len(srcyear); i++ {
-				if syear == srcyear[i] {
-					year = uint32(dstyear[i] - '0')
-					break
-				}
+			checksum += 1 * j
+		}
+	}
+	return checksum
+}
+
+var legacyMLBCodesOnce sync.Once
+var legacyMLBCodes []string
+
+// getLegacyMLBCodes returns all the distinct codes that CCC can generate for legacy MLBs
+func getLegacyMLBCodes() []string {
+	legacyMLBCodesOnce.Do(func() {
+		seen := make(map[string]bool)
+		// It was likely meant to be written as pseudoRandom() % 0x8000.
+		for r := uint32(0); r < 0x7FFE; r++ {
+			// the multiplication overflows exactly as in CCC
+			code, err := getAscii7(r*0x73BA1C, 3)
+			if err != nil || seen[string(code)] {
+				continue
 			}
+			seen[string(code)] = true
+			legacyMLBCodes = append(legacyMLBCodes, string(code))
+		}
+	})
+	return legacyMLBCodes
+}
 
-			overrides := "DGJLNQSVXZ"
-			for i := 0; i < len(overrides); i++ {
-				if syear == overrides[i] {
-					week = 27
-					break
-				}
+// mlbDate returns the year and week used by the MLB, the week before the serial production week
+func (s *Serial) mlbDate() (uint32, uint32, error) {
+	year := uint32(0)
+	week := uint32(0)
+
+	if s.Legacy {
+		year = uint32(s.Year[0] - '0')
+		week = uint32(s.Week[0]-'0')*10 + uint32(s.Week[1]-'0')
+	} else {
+		syear := s.Year[0]
+		sweek := s.Week[0]
+
+		srcyear := "CDFGHJKLMNPQRSTVWXYZ"
+		dstyear := "00112233445566778899"
+		for i := 0; i < len(srcyear); i++ {
+			if syear == srcyear[i] {
+				year = uint32(dstyear[i] - '0')
+				break
 			}
+		}
 
-			srcweek := "123456789CDFGHJKLMNPQRSTVWXYZ"
-			for i := 0; i < len(srcweek); i++ {
-				if sweek == srcweek[i] {
-					week += uint32(i) + 1
-					break
-				}
+		overrides := "DGJLNQSVXZ"
+		for i := 0; i < len(overrides); i++ {
+			if syear == overrides[i] {
+				week = 27
+				break
 			}
-			// This is silently not handled, and it should not be needed for normal serials.
-			// Bugged MacBookPro6,2 and MacBookPro7,1 will gladly hit it.
-			if week < SERIAL_WEEK_MIN {
-				return fmt.Sprintf("FAIL-ZERO-%c", sweek)
+		}
+
+		srcweek := "123456789CDFGHJKLMNPQRSTVWXYZ"
+		for i := 0; i < len(srcweek); i++ {
+			if sweek == srcweek[i] {
+				week += uint32(i) + 1
+				break
 			}
 		}
+		// This should not be needed for normal serials.
+		// Bugged MacBookPro6,2 and MacBookPro7,1 will gladly hit it.
+		if week < SERIAL_WEEK_MIN {
+			return 0, 0, fmt.Errorf("Invalid week symbol '%c' for MLB", sweek)
+		}
+	}
 
-		week--
+	week--
 
-		if week <= 9 {
-			if week == 0 {
-				week = SERIAL_WEEK_MAX
-				if year == 0 {
-					year = 9
-				} else {
-					year--
-				}
+	if week <= 9 {
+		if week == 0 {
+			week = SERIAL_WEEK_MAX
+			if year == 0 {
+				year = 9
+			} else {
+				year--
 			}
 		}
+	}
+	return year, week, nil
+}
 
-		var serial string
-		if legacy {
-			// The loop is not present in CCC, but it throws an exception here,
-			// and effectively generates nothing. The logic is crazy :/.
-			// Also, it was likely meant to be written as pseudoRandom() % 0x8000.
-			var code []byte
-			var err error
-			for {
-				code, err = getAscii7(uint32(pseudoRandomBetween(0, 0x7FFE))*0x73BA1C, 3)
-				if err == nil {
-					break
+// legacyMLB builds the legacy MLB for the code, the suffix is chosen to make the checksum valid
+// the suffix is the last symbol and has weight 1, so there is always exactly one valid suffix
+func legacyMLB(prefix, code, board string) string {
+	size := len(prefix) + len(code) + len(board) + 1
+	checksum := mlbChecksumPart(prefix, 0, size) + mlbChecksumPart(code, len(prefix), size) +
+		mlbChecksumPart(board, len(prefix)+len(code), size)
+	suffix := mlbAlphabet[(len(mlbAlphabet)-checksum%len(mlbAlphabet))%len(mlbAlphabet)]
+	return fmt.Sprintf("%s%s%s%c", prefix, code, board, suffix)
+}
+
+// mlbPrefix returns the common start of all the MLBs of the serial
+func (s *Serial) mlbPrefix() (string, error) {
+	// This is a direct reverse from CCC, rework it later...
+	if s.index < 0 {
+		fmt.Printf("WARN: Unknown model, assuming default!\n")
+		s.index = APPLE_MODEL_MAX - 1
+	}
+
+	year, week, err := s.mlbDate()
+	if err != nil {
+		return "", err
+	}
+	if s.Legacy {
+		// For old MLB, this is a variant of base 34 value. First item character is always 0.
+		return fmt.Sprintf("%s%d%02d0", s.Country, year, week), nil
+	}
+	return fmt.Sprintf("%s%d%02d", s.Country, year, week), nil
+}
+
+// blockSums returns the checksum contribution of each block entry placed at offset
+func blockSums(block []string, offset int, size int) []int {
+	sums := make([]int, len(block))
+	for i, b := range block {
+		sums[i] = mlbChecksumPart(b, offset, size)
+	}
+	return sums
+}
+
+// mlbCombos returns the modern MLB block combinations with a valid checksum
+// each combination has the indexes of the mlbBlocks entries
+func (s *Serial) mlbCombos(prefix string, board string) [][3]int {
+	// blocks are fixed length so all the offsets are known in advance
+	off1 := len(prefix)
+	off2 := off1 + len(mlbBlocks[0][0])
+	offBoard := off2 + len(mlbBlocks[1][0])
+	off3 := offBoard + len(board)
+	size := off3 + len(mlbBlocks[2][0])
+	base := mlbChecksumPart(prefix, 0, size) + mlbChecksumPart(board, offBoard, size)
+	sums1 := blockSums(mlbBlocks[0], off1, size)
+	sums2 := blockSums(mlbBlocks[1], off2, size)
+	sums3 := blockSums(mlbBlocks[2], off3, size)
+
+	var combos [][3]int
+	for i := range sums1 {
+		for j := range sums2 {
+			for k := range sums3 {
+				if (base+sums1[i]+sums2[j]+sums3[k])%len(mlbAlphabet) == 0 {
+					combos = append(combos, [3]int{i, j, k})
 				}
 			}
-			board := getBoardCode(AppleModel(s.index), false)
-			suffix := AppleBase34Reverse[pseudoRandom()%34]
-			// For old MLB, this is a variant of base 34 value. First item character is always 0.
-			serial = fmt.Sprintf("%s%d%02d0%s%s%c", s.Country, year, week, string(code), board, suffix)
-		} else {
-			part1 := MLBBlock1[pseudoRandom()%len(MLBBlock1)]
-			part2 := MLBBlock2[pseudoRandom()%len(MLBBlock2)]
-			board := getBoardCode(AppleModel(s.index), false)
-			part3 := MLBBlock3[pseudoRandom()%len(MLBBlock3)]
-			serial = fmt.Sprintf("%s%d%02d%s%s%s%s", s.Country, year, week, part1, part2, board, part3)
 		}
-		// there is no other exit other than a valid serial, usually this function is called
-		// after serial has been validated
-		if VerifyMLBChecksum(serial) {
-			return serial
+	}
+	return combos
+}
+
+// modernMLB builds the modern MLB from a combination returned by mlbCombos
+func modernMLB(prefix string, board string, combo [3]int) string {
+	return prefix + mlbBlocks[0][combo[0]] + mlbBlocks[1][combo[1]] + board + mlbBlocks[2][combo[2]]
+}
+
+// MLBCandidates returns all the MLBs with a valid checksum that can be generated for the serial
+// the order is always the same for the same serial and board code
+func (s *Serial) MLBCandidates() ([]string, error) {
+	prefix, err := s.mlbPrefix()
+	if err != nil {
+		return nil, err
+	}
+	board := s.board()
+
+	var candidates []string
+	if s.Legacy {
+		// codes are distinct and each one has a single valid suffix so there are no duplicates
+		for _, code := range getLegacyMLBCodes() {
+			candidates = append(candidates, legacyMLB(prefix, code, board))
 		}
+		return candidates, nil
+	}
+
+	// blocks are distinct and fixed length so there are no duplicates either
+	for _, combo := range s.mlbCombos(prefix, board) {
+		candidates = append(candidates, modernMLB(prefix, board, combo))
 	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("No valid MLB exists for serial %s and board code %s", s.String(), board)
+	}
+	return candidates, nil
+}
+
+// MLB generates a MLB from the serial number
+// it is picked uniformly among all the valid candidates
+func (s *Serial) MLB() (string, error) {
+	prefix, err := s.mlbPrefix()
+	if err != nil {
+		return "", err
+	}
+	board := s.board()
+	// no need to build all the candidates strings, just pick one
+	if s.Legacy {
+		codes := getLegacyMLBCodes()
+		return legacyMLB(prefix, codes[pseudoRandom(s.rnd)%len(codes)], board), nil
+	}
+	combos := s.mlbCombos(prefix, board)
+	if len(combos) == 0 {
+		return "", fmt.Errorf("No valid MLB exists for serial %s and board code %s", s.String(), board)
+	}
+	return modernMLB(prefix, board, combos[pseudoRandom(s.rnd)%len(combos)]), nil
+}
+
+// printJSON prints v as indented JSON
+func printJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s\n", data)
+}
+
+// normalizeInput normalizes a user supplied serial and lets the user know about it
+func normalizeInput(serial string) string {
+	ret := normalizeSerial(serial)
+	if ret != serial {
+		fmt.Printf("NOTE: Serial %s normalized to %s\n", serial, ret)
+	}
+	return ret
 }
 
 func usage(app string) {
@@ -820,21 +1049,61 @@ func usage(app string) {
 			" --generate       (-g)  generate serial (requires at least model option)\n"+
 			" --generate-all   (-a)  generate serial for all models\n"+
 			" --info <serial>  (-i)  decode serial information\n"+
+			" --repair <serial>      suggest fixes for a mistyped serial\n"+
+			" --enumerate <pattern>  list valid serials matching pattern (? is a wildcard)\n"+
+			" --compare <a> <b>      compare two serials or serial,mlb,rom identities\n"+
+			" --fleet <file>         report similar identities in a list (- for stdin)\n"+
+			" --rom-from-nic [iface] use a network interface MAC address as ROM (Linux)\n"+
+			" --rom-info <rom>       decode and validate a ROM (hex or base64)\n"+
+			" --db-check             verify the model database consistency\n"+
+			" --db-diff <a> [b]      compare model databases (generated .go files or overlays)\n"+
+			" --board-id <id>        find the models using a board-id\n"+
+			" --check-support <file> check the model against an installer PlatformSupport.plist\n"+
+			" --recommend            rank the models for this host CPU (or --cpu)\n"+
 			" --verify <mlb>         verify MLB checksum\n"+
 			" --list           (-l)  list known mac models\n"+
 			" --list-products  (-lp) list known product codes\n"+
+			" --product <code>       show the description and models of a product code\n"+
+			" --product-search <txt> find product codes by description\n"+
 			" --mlb <serial>         generate MLB based on serial\n"+
 			" --sys            (-s)  get system info\n"+
 			" --uuid           (-u)  generate UUID\n\n"+
 			"Options:\n"+
 			" --model <model>  (-m)  mac model (index or string) used for generation\n"+
 			" --num <num>      (-n)  number of generated pairs\n"+
-			" --year <year>    (-y)  year used for generation\n"+
+			" --year <year>    (-y)  year used for generation or --list filter\n"+
 			" --week <week>    (-w)  week used for generation\n"+
 			" --country <loc>  (-c)  country location used for generation\n"+
 			" --copy <copy>    (-o)  production copy index\n"+
 			" --line <line>    (-e)  production line\n"+
-			" --platform <ppp> (-p)  3 or 4 digit string model code used for generation\n\n", app)
+			" --from <date>          production date range start (YYYY-Www or YYYY-MM-DD)\n"+
+			" --to <date>            production date range end (YYYY-Www or YYYY-MM-DD)\n"+
+			" --platform <ppp> (-p)  3 or 4 digit string model code used for generation\n"+
+			" --count                only display the number of enumerated serials\n"+
+			" --profile <name>       generation profile: stable (default) or realistic\n"+
+			" --codes <mode>         model and board code selection: first, uniform or weighted\n"+
+			" --countries <mode>     country selection: base or plausible for the model years\n"+
+			" --model-code <code>    model code used for generation (must belong to model)\n"+
+			" --board-code <code>    board code used for MLB generation (must belong to model) or --list filter\n"+
+			" --unique               skip identities with repeated serial, MLB or ROM\n"+
+			" --workers <num>        number of parallel generators\n"+
+			" --seed <seed>          seed for reproducible (and insecure) generation\n"+
+			" --out <file>           write generated identities to file\n"+
+			" --copies <min-max>     only derivative serials in copy range\n"+
+			" --no-original          exclude the serial itself from derivative serials\n"+
+			" --valid-only           only derivative serials considered valid\n"+
+			" --min-cluster <num>    minimum size of reported fleet clusters\n"+
+			" --show <value>         show a model value (board-id, bios-version, smc-branch...)\n"+
+			" --supports <version>   only list models supported by a macOS version\n"+
+			" --family <family>      only list models of a family (MacBookPro, iMac...)\n"+
+			" --code <code>          only list models owning a model code\n"+
+			" --search <text>        only list models whose product descriptions contain text\n"+
+			" --compact              list models as a table\n"+
+			" --nic <iface>          same as --rom-from-nic <iface> (default first physical)\n"+
+			" --macos <version>      warn if the keygen model is not supported by a macOS version\n"+
+			" --cpu <name>           CPU name used by --recommend instead of /proc/cpuinfo\n"+
+			" --json                 output in JSON format\n"+
+			" --db <file>            merge a JSON or YAML model database overlay\n\n", app)
 }
 
 func main() {
@@ -843,9 +1112,22 @@ func main() {
 	var cmdGenerate bool
 	var cmdGenerateAll bool
 	var cmdInfo string
+	var cmdRepair string
+	var cmdEnumerate string
+	var cmdCompare string
+	var cmdFleet string
+	var cmdROMFromNIC bool
+	var cmdROMInfo string
+	var cmdDBCheck bool
+	var cmdDBDiff string
+	var cmdBoardID string
+	var cmdCheckSupport string
+	var cmdRecommend bool
 	var cmdVerify string
 	var cmdList bool
 	var cmdListProds bool
+	var cmdProduct string
+	var cmdProductSearch string
 	var cmdMLB string
 	var cmdDeriv string
 	var cmdSys bool
@@ -859,6 +1141,33 @@ func main() {
 	var optModelCode string
 	var optCopy int
 	var optLine int
+	var optCount bool
+	var optFrom string
+	var optTo string
+	var optProfile string
+	var optCodes string
+	var optCountries string
+	var optPinModel string
+	var optPinBoard string
+	var optUnique bool
+	var optWorkers int
+	var optSeed int64
+	var optOut string
+	var optCopies string
+	var optNoOriginal bool
+	var optValidOnly bool
+	var optJSON bool
+	var optMinCluster int
+	var optDB string
+	var optShow string
+	var optSupports string
+	var optMacOS string
+	var optCPU string
+	var optFamily string
+	var optCode string
+	var optSearch string
+	var optCompact bool
+	var optNIC string
 	// https://www.antoniojgutierrez.com/posts/2021-05-14-short-and-long-options-in-go-flags-pkg/
 	flag.BoolVar(&cmdHelp, "h", false, "show this help")
 	flag.BoolVar(&cmdHelp, "help", false, "show this help")
@@ -870,11 +1179,24 @@ func main() {
 	flag.BoolVar(&cmdGenerateAll, "generate-all", false, "")
 	flag.StringVar(&cmdInfo, "i", "", "")
 	flag.StringVar(&cmdInfo, "info", "", "")
+	flag.StringVar(&cmdRepair, "repair", "", "")
+	flag.StringVar(&cmdEnumerate, "enumerate", "", "")
+	flag.StringVar(&cmdCompare, "compare", "", "")
+	flag.StringVar(&cmdFleet, "fleet", "", "")
+	flag.BoolVar(&cmdROMFromNIC, "rom-from-nic", false, "")
+	flag.StringVar(&cmdROMInfo, "rom-info", "", "")
+	flag.BoolVar(&cmdDBCheck, "db-check", false, "")
+	flag.StringVar(&cmdDBDiff, "db-diff", "", "")
+	flag.StringVar(&cmdBoardID, "board-id", "", "")
+	flag.StringVar(&cmdCheckSupport, "check-support", "", "")
+	flag.BoolVar(&cmdRecommend, "recommend", false, "")
 	flag.StringVar(&cmdVerify, "verify", "", "")
 	flag.BoolVar(&cmdList, "l", false, "")
 	flag.BoolVar(&cmdList, "list", false, "")
 	flag.BoolVar(&cmdListProds, "lp", false, "")
 	flag.BoolVar(&cmdListProds, "list-products", false, "")
+	flag.StringVar(&cmdProduct, "product", "", "")
+	flag.StringVar(&cmdProductSearch, "product-search", "", "")
 	flag.StringVar(&cmdMLB, "mlb", "", "")
 	flag.StringVar(&cmdDeriv, "d", "", "")
 	flag.StringVar(&cmdDeriv, "deriv", "", "")
@@ -901,9 +1223,65 @@ func main() {
 	flag.IntVar(&optCopy, "copy", -1, "")
 	flag.IntVar(&optLine, "e", -1, "")
 	flag.IntVar(&optLine, "line", -1, "")
+	flag.BoolVar(&optCount, "count", false, "")
+	flag.StringVar(&optFrom, "from", "", "")
+	flag.StringVar(&optTo, "to", "", "")
+	flag.StringVar(&optProfile, "profile", "stable", "")
+	flag.StringVar(&optCodes, "codes", "", "")
+	flag.StringVar(&optCountries, "countries", "", "")
+	flag.StringVar(&optPinModel, "model-code", "", "")
+	flag.StringVar(&optPinBoard, "board-code", "", "")
+	flag.BoolVar(&optUnique, "unique", false, "")
+	flag.IntVar(&optWorkers, "workers", 1, "")
+	flag.Int64Var(&optSeed, "seed", -1, "")
+	flag.StringVar(&optOut, "out", "", "")
+	flag.StringVar(&optCopies, "copies", "", "")
+	flag.BoolVar(&optNoOriginal, "no-original", false, "")
+	flag.BoolVar(&optValidOnly, "valid-only", false, "")
+	flag.BoolVar(&optJSON, "json", false, "")
+	flag.IntVar(&optMinCluster, "min-cluster", FLEET_MIN_CLUSTER, "")
+	flag.StringVar(&optDB, "db", "", "")
+	flag.StringVar(&optShow, "show", "", "")
+	flag.StringVar(&optSupports, "supports", "", "")
+	flag.StringVar(&optMacOS, "macos", "", "")
+	flag.StringVar(&optCPU, "cpu", "", "")
+	flag.StringVar(&optFamily, "family", "", "")
+	flag.StringVar(&optCode, "code", "", "")
+	flag.StringVar(&optSearch, "search", "", "")
+	flag.BoolVar(&optCompact, "compact", false, "")
+	flag.StringVar(&optNIC, "nic", "", "")
 	// set the usage because of duplicate commands
 	flag.Usage = func() { usage(os.Args[0]) }
 	flag.Parse()
+	// the flag package stops at the first positional argument, so --compare a b --json
+	// leaves --json unparsed, keep the second identity and parse the rest again
+	var compareWith string
+	if cmdCompare != "" && flag.NArg() > 0 {
+		compareWith = flag.Arg(0)
+		if err := flag.CommandLine.Parse(flag.Args()[1:]); err != nil {
+			os.Exit(2)
+		}
+	}
+	// and the second database of --db-diff
+	var diffWith string
+	if cmdDBDiff != "" && cmdCompare == "" && flag.NArg() > 0 {
+		diffWith = flag.Arg(0)
+		if err := flag.CommandLine.Parse(flag.Args()[1:]); err != nil {
+			os.Exit(2)
+		}
+	}
+	// same for the optional interface of --rom-from-nic, the flag package has no optional values
+	if cmdROMFromNIC && cmdCompare == "" && cmdDBDiff == "" && flag.NArg() > 0 {
+		iface := flag.Arg(0)
+		if err := flag.CommandLine.Parse(flag.Args()[1:]); err != nil {
+			os.Exit(2)
+		}
+		if optNIC != "" {
+			fmt.Printf("ERROR: Interface set by both --rom-from-nic %s and --nic %s\n", iface, optNIC)
+			os.Exit(1)
+		}
+		optNIC = iface
+	}
 
 	// commands that don't depend on options
 	if cmdHelp {
@@ -921,9 +1299,116 @@ func main() {
 		os.Exit(0)
 	}
 
+	// --db must be loaded before anything uses the model tables
+	if optDB != "" {
+		if err := loadDatabase(optDB); err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	// --db-check after --db so overlays are also verified
+	if cmdDBCheck {
+		problems := checkDatabase()
+		for _, p := range problems {
+			fmt.Printf("ERROR: %s\n", p)
+		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+		fmt.Printf("Model database OK: %d models, %d product descriptions\n", len(ApplePlatformData), len(AppleModelDesc))
+		os.Exit(0)
+	}
+	// --db-diff compares the current database to a file or two files
+	if cmdDBDiff != "" {
+		if flag.NArg() > 0 {
+			fmt.Printf("ERROR: Unexpected argument %s, --db-diff compares at most two databases\n", flag.Arg(0))
+			os.Exit(1)
+		}
+		a, b := currentDB(), ModelDB{}
+		var err error
+		if diffWith != "" {
+			a, err = readDB(cmdDBDiff)
+			if err == nil {
+				b, err = readDB(diffWith)
+			}
+		} else {
+			b, err = readDB(cmdDBDiff)
+		}
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		diff := diffDB(a, b)
+		if optJSON {
+			printJSON(diff)
+		} else {
+			diff.Print()
+		}
+		os.Exit(0)
+	}
+
+	// --board-id
+	if cmdBoardID != "" {
+		models := findBoardID(cmdBoardID)
+		if len(models) == 0 {
+			if !hasBoardIDs() {
+				fmt.Printf("ERROR: The model database has no board-ids, regenerate it or load them with --db\n")
+			} else {
+				fmt.Printf("ERROR: Unknown board-id %s\n", cmdBoardID)
+			}
+			os.Exit(1)
+		}
+		if optJSON {
+			match := BoardIDMatch{BoardID: cmdBoardID}
+			for _, i := range models {
+				match.Models = append(match.Models, ApplePlatformData[i].productName)
+			}
+			printJSON(match)
+			os.Exit(0)
+		}
+		for _, i := range models {
+			fmt.Printf("%14s: %s\n", "Model", ApplePlatformData[i].productName)
+			fmt.Printf("%14s: %d\n", "Model Index", i)
+		}
+		if len(models) > 1 {
+			fmt.Printf("WARN: Board-id %s is shared by %d models, it can't tell them apart\n", cmdBoardID, len(models))
+		}
+		os.Exit(0)
+	}
+
+	// --recommend uses the host CPU unless --cpu is set
+	if cmdRecommend {
+		name := optCPU
+		if name == "" {
+			var err error
+			if name, err = hostCPU(procCPUInfo); err != nil {
+				fmt.Printf("ERROR: %s, use --cpu\n", err)
+				os.Exit(1)
+			}
+		}
+		var version []int
+		if optMacOS != "" {
+			var err error
+			if version, err = parseMacOSVersion(optMacOS); err != nil {
+				fmt.Printf("ERROR: %s\n", err)
+				os.Exit(1)
+			}
+		}
+		r := recommendModels(parseCPU(name), optMacOS, version)
+		if optJSON {
+			if len(r.Models) > optNum {
+				r.Models = r.Models[:optNum]
+			}
+			printJSON(r)
+		} else {
+			r.Print(optNum)
+		}
+		os.Exit(0)
+	}
+
 	// this is the most used model
 	defaultIndex := 0
-	for i := 0; i < APPLE_MODEL_MAX; i++ {
+	for i := 0; i < len(ApplePlatformData); i++ {
 		if "iMacPro1,1" == ApplePlatformData[i].productName {
 			defaultIndex = i
 			break
@@ -945,7 +1430,7 @@ func main() {
 		value, err := strconv.Atoi(optModel)
 		// error means the user inserted the model string instead
 		if err != nil {
-			for i := 0; i < APPLE_MODEL_MAX; i++ {
+			for i := 0; i < len(ApplePlatformData); i++ {
 				if optModel == ApplePlatformData[i].productName {
 					args.Index = i
 					break
@@ -956,6 +1441,106 @@ func main() {
 		}
 	}
 
+	// the default model is used if the model is unknown so check that it was really found
+	knownModel := func() bool {
+		return args.Index >= 0 && args.Index < len(ApplePlatformData) &&
+			(strconv.Itoa(args.Index) == optModel || ApplePlatformData[args.Index].productName == optModel)
+	}
+	// --show needs an explicit model and not the default one
+	if optShow != "" {
+		if optModel == "" {
+			fmt.Printf("ERROR: --show requires a --model\n")
+			os.Exit(1)
+		}
+		if !knownModel() {
+			fmt.Printf("ERROR: Unknown model %s\n", optModel)
+			os.Exit(1)
+		}
+		values, err := showSMBIOSField(AppleModel(args.Index), optShow)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		for _, v := range values {
+			fmt.Println(v)
+		}
+		os.Exit(0)
+	}
+
+	// --check-support without --model only lists the supported models
+	if cmdCheckSupport != "" {
+		ps, err := readPlatformSupport(cmdCheckSupport)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		model := -1
+		if optModel != "" {
+			if !knownModel() {
+				fmt.Printf("ERROR: Unknown model %s\n", optModel)
+				os.Exit(1)
+			}
+			model = args.Index
+		}
+		c := checkSupport(ps, model)
+		if optJSON {
+			printJSON(c)
+		} else {
+			c.Print()
+		}
+		if model >= 0 && !c.Supported {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// -l  || --list
+	// before the generation options because --year and --board-code are filters here
+	if cmdList {
+		filter := ListFilter{Family: optFamily, Year: optYear, Code: optCode, BoardCode: optPinBoard, Search: optSearch}
+		if optSupports != "" {
+			var err error
+			if filter.Supports, err = parseMacOSVersion(optSupports); err != nil {
+				fmt.Printf("ERROR: %s\n", err)
+				os.Exit(1)
+			}
+			if !hasMacOSSupport() {
+				fmt.Printf("ERROR: The model database has no macOS support information, load it with --db\n")
+				os.Exit(1)
+			}
+		}
+		models := listModels(filter)
+		if optJSON {
+			printJSON(models)
+			os.Exit(0)
+		}
+		if len(models) == 0 {
+			fmt.Printf("No models found\n")
+			os.Exit(1)
+		}
+		if optCompact {
+			printModelTable(models)
+			os.Exit(0)
+		}
+		fmt.Printf("Available models:\n")
+		for _, m := range models {
+			m.Print()
+			fmt.Println("")
+		}
+		if !filter.Empty() {
+			os.Exit(0)
+		}
+		fmt.Printf("Available legacy location codes:\n")
+		for j := 0; j < len(AppleLegacyLocations); j++ {
+			fmt.Printf(" - %s, %s\n", AppleLegacyLocations[j], AppleLegacyLocationNames[j])
+		}
+		fmt.Printf("\nAvailable new location codes:\n")
+		for j := 0; j < len(AppleLocations); j++ {
+			fmt.Printf(" - %s, %s\n", AppleLocations[j], AppleLocationNames[j])
+		}
+		os.Exit(0)
+	}
+
 	if optYear != -1 {
 		if optYear < SERIAL_YEAR_MIN || optYear > SERIAL_YEAR_MAX {
 			fmt.Printf("ERROR: Year %d is out of valid range [%d, %d]!\n", optYear, SERIAL_YEAR_MIN, SERIAL_YEAR_MAX)
@@ -973,11 +1558,9 @@ func main() {
 		args.Week = optWeek
 	}
 
-	// XXX: it will accept any country - shouldn't we have a lookup table?
 	if optCountry != "" {
-		len := len(optCountry)
-		if len != COUNTRY_OLD_LEN && len != COUNTRY_NEW_LEN {
-			fmt.Printf("ERROR: Country location %s is neither %d nor %d symbols long!\n", optCountry, COUNTRY_OLD_LEN, COUNTRY_NEW_LEN)
+		if err := validateCountry(optCountry); err != nil {
+			fmt.Printf("ERROR: %s!\n", err)
 			os.Exit(1)
 		}
 		args.Country = optCountry
@@ -991,6 +1574,10 @@ func main() {
 			os.Exit(1)
 		}
 		args.ModelCode = optModelCode
+		// the default model would otherwise conflict with the platform code
+		if optModel == "" {
+			args.Index = -1
+		}
 	}
 
 	if optCopy != -1 {
@@ -1009,37 +1596,99 @@ func main() {
 		args.Line = optLine
 	}
 
+	if optFrom != "" || optTo != "" {
+		if optYear != -1 || optWeek != -1 {
+			fmt.Printf("ERROR: --from and --to options can't be used with --year or --week.\n")
+			os.Exit(1)
+		}
+		args.From = ProductionWeek{Year: SERIAL_YEAR_MIN, Week: SERIAL_WEEK_MIN}
+		args.To = ProductionWeek{Year: SERIAL_YEAR_MAX, Week: SERIAL_WEEK_MAX}
+		var err error
+		if optFrom != "" {
+			if args.From, err = parseProductionWeek(optFrom); err != nil {
+				fmt.Printf("ERROR: %s\n", err)
+				os.Exit(1)
+			}
+		}
+		if optTo != "" {
+			if args.To, err = parseProductionWeek(optTo); err != nil {
+				fmt.Printf("ERROR: %s\n", err)
+				os.Exit(1)
+			}
+		}
+		// fail early instead of once for every generated serial
+		if args.Index < len(ApplePlatformData) && (args.Index >= 0 || args.ModelCode != "") {
+			code := args.ModelCode
+			if code == "" {
+				code = AppleModelCode[args.Index][0]
+			}
+			if _, err := pickProductionWeek(args.Index, len(code) != MODEL_CODE_NEW_LEN, args.From, args.To, nil); err != nil {
+				fmt.Printf("ERROR: %s\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
 	if args.Index >= 0 && args.ModelCode != "" {
 		fmt.Printf("ERROR: --model and --platform options are mutually exclusive. Please set only one.\n")
 		os.Exit(1)
 	}
 
-	// and now execute the commands
-	// -l  || --list
-	if cmdList {
-		fmt.Printf("Available models:\n")
-		for j := 0; j < APPLE_MODEL_MAX; j++ {
-			fmt.Printf("%14s: %s\n", "Model", ApplePlatformData[j].productName)
-			fmt.Printf("%14s: %d\n", "Model Index", j)
-			fmt.Printf("%14s: ", "Prod years")
-			getProductionYear(AppleModel(j), true)
-			fmt.Printf("%14s: %s\n", "Base Serial", ApplePlatformData[j].serialNumber)
-			fmt.Printf("%14s: ", "Model codes")
-			getModelCode(AppleModel(j), true)
-			fmt.Printf("%14s: ", "Board codes")
-			getBoardCode(AppleModel(j), true)
-			fmt.Println("")
+	profile, err := findProfile(optProfile)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	args.CodePick = profile.codePick
+	if optCodes != "" {
+		if args.CodePick, err = findCodePick(optCodes); err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
 		}
-		fmt.Printf("Available legacy location codes:\n")
-		for j := 0; j < len(AppleLegacyLocations); j++ {
-			fmt.Printf(" - %s, %s\n", AppleLegacyLocations[j], AppleLegacyLocationNames[j])
+	}
+	// fail early instead of once for every generated serial
+	if args.Country != "" && args.Index >= 0 && args.Index < len(ApplePlatformData) {
+		code := AppleModelCode[args.Index][0]
+		if args.ModelCode != "" {
+			code = args.ModelCode
 		}
-		fmt.Printf("\nAvailable new location codes:\n")
-		for j := 0; j < len(AppleLocations); j++ {
-			fmt.Printf(" - %s, %s\n", AppleLocations[j], AppleLocationNames[j])
+		if (len(args.Country) == COUNTRY_NEW_LEN) != (len(code) == MODEL_CODE_NEW_LEN) {
+			fmt.Printf("ERROR: Country location %s does not match %s serial format\n", args.Country, ApplePlatformData[args.Index].productName)
+			os.Exit(1)
+		}
+	}
+	args.CountryPick = profile.countryPick
+	if optCountries != "" {
+		if args.CountryPick, err = findCountryPick(optCountries); err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if optPinModel != "" || optPinBoard != "" {
+		if args.Index < 0 || args.Index >= len(ApplePlatformData) {
+			fmt.Printf("ERROR: --model-code and --board-code options require a valid --model\n")
+			os.Exit(1)
+		}
+		if optPinModel != "" {
+			if findCode(AppleModelCode[args.Index][:], optPinModel) < 0 {
+				fmt.Printf("ERROR: Model code %s does not belong to %s, valid codes: ", optPinModel, ApplePlatformData[args.Index].productName)
+				getModelCode(AppleModel(args.Index), true)
+				os.Exit(1)
+			}
+			args.ModelCode = optPinModel
+		}
+		if optPinBoard != "" {
+			if findCode(AppleBoardCode[args.Index][:], optPinBoard) < 0 {
+				fmt.Printf("ERROR: Board code %s does not belong to %s, valid codes: ", optPinBoard, ApplePlatformData[args.Index].productName)
+				getBoardCode(AppleModel(args.Index), true)
+				os.Exit(1)
+			}
+			args.BoardCode = optPinBoard
 		}
-		os.Exit(0)
 	}
+
+	// and now execute the commands
 	// -lp || --list-products
 	if cmdListProds {
 		for j := 0; j < len(AppleModelDesc); j++ {
@@ -1047,9 +1696,39 @@ func main() {
 		}
 		os.Exit(0)
 	}
+	// --product
+	if cmdProduct != "" {
+		p, err := lookupProduct(cmdProduct)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		if optJSON {
+			printJSON(p)
+		} else {
+			p.Print()
+		}
+		os.Exit(0)
+	}
+	// --product-search
+	if cmdProductSearch != "" {
+		products := searchProducts(cmdProductSearch)
+		if optJSON {
+			printJSON(products)
+			os.Exit(0)
+		}
+		if len(products) == 0 {
+			fmt.Printf("No product codes found\n")
+			os.Exit(1)
+		}
+		for _, p := range products {
+			p.PrintLine()
+		}
+		os.Exit(0)
+	}
 	// -i || --info
 	if cmdInfo != "" {
-		s, err := parseSerial(cmdInfo)
+		s, err := parseSerial(normalizeInput(cmdInfo))
 		if err != nil {
 			fmt.Printf("ERROR: %s\n", err)
 			os.Exit(1)
@@ -1057,13 +1736,133 @@ func main() {
 		s.Print()
 		os.Exit(0)
 	}
+	// --repair
+	if cmdRepair != "" {
+		candidates := repairSerial(cmdRepair)
+		if len(candidates) == 0 {
+			fmt.Printf("ERROR: No candidates found for %s\n", cmdRepair)
+			os.Exit(1)
+		}
+		for i := 0; i < len(candidates) && i < optNum; i++ {
+			c := candidates[i]
+			fmt.Printf("%s | score %2d | %d replaced | %s %d-%02d %s\n", c.Serial, c.Score, c.Subst,
+				c.Decoded.ProductName, c.Decoded.DecodedYear, c.Decoded.DecodedWeek, c.Decoded.CountryDesc)
+		}
+		os.Exit(0)
+	}
+	// --enumerate
+	if cmdEnumerate != "" {
+		var fn func(Serial) bool
+		if !optCount {
+			fn = func(s Serial) bool {
+				fmt.Printf("%s\n", s.String())
+				return true
+			}
+		}
+		count, err := enumerateSerials(cmdEnumerate, fn)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		if optCount {
+			fmt.Printf("%d\n", count)
+		}
+		os.Exit(0)
+	}
+	// --compare
+	if cmdCompare != "" {
+		if compareWith == "" || flag.NArg() != 0 {
+			fmt.Printf("ERROR: --compare requires two serials or identities\n")
+			os.Exit(1)
+		}
+		a, err := parseIdentity(cmdCompare)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		b, err := parseIdentity(compareWith)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		c, err := compareIdentities(a, b)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		if optJSON {
+			printJSON(c)
+		} else {
+			c.Print()
+		}
+		os.Exit(0)
+	}
+	// --fleet
+	if cmdFleet != "" {
+		in := os.Stdin
+		if cmdFleet != "-" {
+			in, err = os.Open(cmdFleet)
+			if err != nil {
+				fmt.Printf("ERROR: %s\n", err)
+				os.Exit(1)
+			}
+		}
+		ids, err := readIdentities(in)
+		in.Close()
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		report := analyzeFleet(ids, optMinCluster)
+		if optJSON {
+			printJSON(report)
+		} else {
+			report.Print()
+		}
+		os.Exit(0)
+	}
+	// --rom-info
+	if cmdROMInfo != "" {
+		info, err := romInfo(cmdROMInfo)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		if optJSON {
+			printJSON(info)
+		} else {
+			info.Print()
+		}
+		os.Exit(0)
+	}
+	// only a single interface can be used
+	if cmdROMFromNIC && flag.NArg() > 0 {
+		fmt.Printf("ERROR: Unexpected argument %s\n", flag.Arg(0))
+		os.Exit(1)
+	}
+	if optNIC != "" && !cmdROMFromNIC {
+		fmt.Printf("ERROR: --nic requires --rom-from-nic\n")
+		os.Exit(1)
+	}
+	// --rom-from-nic without --keygen
+	if cmdROMFromNIC && !cmdKeygen {
+		nic, err := findNIC(sysNetRoot, optNIC)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Interface:    %s\n", nic.Name)
+		fmt.Printf("ROM:          %s\n", nic.ROM())
+		fmt.Printf("ROM (data):   %s\n", nic.ROMData())
+		os.Exit(0)
+	}
 	// --verify
 	if cmdVerify != "" {
 		slen := len(cmdVerify)
 		switch slen {
-		case 13:
+		case MLB_OLD_LEN:
 			fmt.Printf("Valid MLB length: legacy\n")
-		case 17:
+		case MLB_NEW_LEN:
 			fmt.Printf("Valid MLB length: modern\n")
 		default:
 			fmt.Printf("ERROR: Invalid MLB length: %d\n", slen)
@@ -1076,6 +1875,69 @@ func main() {
 		}
 		os.Exit(0)
 	}
+	derivOpts := DefaultDerivOptions()
+	derivOpts.ExcludeOriginal = optNoOriginal
+	derivOpts.OnlyValid = optValidOnly
+	if optCopies != "" {
+		if derivOpts.CopyMin, derivOpts.CopyMax, err = parseCopyRange(optCopies); err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// bulk generation options
+	bulk := optUnique || optWorkers != 1 || optSeed >= 0 || optOut != ""
+	if bulk && (cmdGenerate || cmdGenerateAll) {
+		if optWorkers < 1 {
+			fmt.Printf("ERROR: Number of workers must be at least 1\n")
+			os.Exit(1)
+		}
+		if args.Index < 0 || args.Index >= len(ApplePlatformData) {
+			fmt.Printf("ERROR: Bulk generation requires a valid model option\n")
+			os.Exit(1)
+		}
+		opts := BulkOptions{
+			Params:  args,
+			Models:  []int{args.Index},
+			Num:     optNum,
+			Unique:  optUnique,
+			Workers: optWorkers,
+			Seed:    optSeed,
+		}
+		if cmdGenerateAll {
+			opts.Models = nil
+			for i := 0; i < len(ApplePlatformData); i++ {
+				opts.Models = append(opts.Models, i)
+			}
+		}
+		out := os.Stdout
+		if optOut != "" {
+			out, err = os.Create(optOut)
+			if err != nil {
+				fmt.Printf("ERROR: %s\n", err)
+				os.Exit(1)
+			}
+		}
+		w := bufio.NewWriter(out)
+		stats, err := generateBulk(opts, func(id Identity) error {
+			_, err := fmt.Fprintf(w, "%s | Serial: %s | MLB: %s | ROM: %s\n", id.ProductName, id.Serial, id.MLB, id.ROM)
+			return err
+		})
+		if ferr := w.Flush(); err == nil {
+			err = ferr
+		}
+		if optOut != "" {
+			if cerr := out.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", stats)
+		os.Exit(0)
+	}
 	// -g || --generate
 	if cmdGenerate {
 		if args.Index == -1 && args.ModelCode == "" {
@@ -1089,14 +1951,18 @@ func main() {
 				fmt.Printf("ERROR: %s\n", err)
 				continue
 			}
-			mlb := s.MLB()
+			mlb, err := s.MLB()
+			if err != nil {
+				fmt.Printf("ERROR: %s\n", err)
+				continue
+			}
 			fmt.Printf("%s | Serial: %s | MLB: %s\n", s.ProductName, s.String(), mlb)
 		}
 		os.Exit(0)
 	}
 	// -a || --generate-all
 	if cmdGenerateAll {
-		for i := 0; i < APPLE_MODEL_MAX; i++ {
+		for i := 0; i < len(ApplePlatformData); i++ {
 			args.Index = i
 			for j := 0; j < optNum; j++ {
 				s, err := generateSerial(args)
@@ -1104,7 +1970,11 @@ func main() {
 					fmt.Printf("ERROR: %s\n", err)
 					continue
 				}
-				mlb := s.MLB()
+				mlb, err := s.MLB()
+				if err != nil {
+					fmt.Printf("ERROR: %s\n", err)
+					continue
+				}
 				fmt.Printf("%14s | %s | %s\n", ApplePlatformData[i].productName, s.String(), mlb)
 			}
 		}
@@ -1112,7 +1982,7 @@ func main() {
 	}
 	// --mlb
 	if cmdMLB != "" {
-		s, err := parseSerial(cmdMLB)
+		s, err := parseSerial(normalizeInput(cmdMLB))
 		if err != nil {
 			fmt.Printf("ERROR: %s\n", err)
 			os.Exit(1)
@@ -1121,26 +1991,25 @@ func main() {
 			fmt.Printf("ERROR: Serial is not valid\n")
 			os.Exit(1)
 		}
-		mlb := s.MLB()
+		if args.BoardCode != "" {
+			s.boardCode = args.BoardCode
+		} else if s.index >= 0 {
+			s.boardCode = pickBoardCode(AppleModel(s.index), args.CodePick, nil)
+		}
+		mlb, err := s.MLB()
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
 		fmt.Printf("%s\n", mlb)
 		os.Exit(0)
 	}
 	// -d || --deriv
 	if cmdDeriv != "" {
-		s, err := parseSerial(cmdDeriv)
-		if err != nil {
+		if err := printDerivatives(os.Stdout, os.Stderr, cmdDeriv, derivOpts, optJSON); err != nil {
 			fmt.Printf("ERROR: %s\n", err)
 			os.Exit(1)
 		}
-		rmin := lineToRmin(s.DecodedLine)
-		for k := 0; k < 34; k++ {
-			start := k * 68
-			if s.DecodedLine > start && s.DecodedLine-start <= SERIAL_LINE_REPR_MAX {
-				rem := s.DecodedLine - start
-				fmt.Printf("%s%s%s%c%c%c%s - copy %d\n", s.Country, s.Year, s.Week, AppleBase34Reverse[k],
-					AppleBase34Reverse[rem/34], AppleBase34Reverse[rem%34], s.Model, k-rmin+1)
-			}
-		}
 		os.Exit(0)
 	}
 
@@ -1150,21 +2019,65 @@ func main() {
 			flag.Usage()
 			os.Exit(1)
 		}
+		var macOSTarget []int
+		if optMacOS != "" {
+			if macOSTarget, err = parseMacOSVersion(optMacOS); err != nil {
+				fmt.Printf("ERROR: %s\n", err)
+				os.Exit(1)
+			}
+		}
 		s, err := generateSerial(args)
 		if err != nil {
 			fmt.Printf("ERROR: %s\n", err)
 			os.Exit(1)
 		}
 
-		mlb := s.MLB()
+		mlb, err := s.MLB()
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
 		uuid := uuid.New()
 		rom := generateROM()
+		romData := ""
+		if cmdROMFromNIC {
+			nic, err := findNIC(sysNetRoot, optNIC)
+			if err != nil {
+				fmt.Printf("ERROR: %s\n", err)
+				os.Exit(1)
+			}
+			rom, romData = nic.ROM(), nic.ROMData()
+		}
 
+		var warnings []string
+		if optMacOS != "" {
+			if w := macOSWarning(AppleModel(s.index), optMacOS, macOSTarget); w != "" {
+				warnings = append(warnings, w)
+			}
+		}
+		smbios := modelSMBIOS(AppleModel(s.index))
+		if optJSON {
+			k := Keygen{Type: s.ProductName, Serial: s.String(), BoardSerial: mlb, UUID: strings.ToUpper(uuid.String()), ROM: rom, ROMData: romData, Warnings: warnings}
+			if !smbios.Empty() {
+				k.SMBIOS = &smbios
+			}
+			printJSON(k)
+			os.Exit(0)
+		}
 		fmt.Printf("Type:         %s\n", s.ProductName)
 		fmt.Printf("Serial:       %s\n", s.String())
 		fmt.Printf("Board Serial: %s\n", mlb)
 		fmt.Printf("UUID:         %s\n", strings.ToUpper(uuid.String()))
 		fmt.Printf("ROM:          %s\n", rom)
+		if romData != "" {
+			fmt.Printf("ROM (data):   %s\n", romData)
+		}
+		for _, f := range smbios.fields() {
+			fmt.Printf("%-14s%s\n", f[0]+":", f[1])
+		}
+		for _, w := range warnings {
+			fmt.Printf("WARN: %s\n", w)
+		}
 		// fmt.Printf("\nYou can verify serial validity at https://checkcoverage.apple.com/\n")
 		// fmt.Printf("You should be looking for a \"We're sorry, we're unable to check coverage for this serial number.\" error message.\n")
 		os.Exit(0)
@@ -34,6 +34,11 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -232,6 +237,1191 @@ func TestMLB(t *testing.T) {
 	if !s.Valid {
 		t.Fatal("Serial is not valid")
 	}
-	// this function never fails for now
-	_ = s.MLB()
+	mlb, err := s.MLB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mlb) != 13 || !VerifyMLBChecksum(mlb) {
+		t.Fatalf("Bad legacy MLB %s", mlb)
+	}
+	// every candidate must be valid and unique
+	candidates, err := s.MLBCandidates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if !VerifyMLBChecksum(c) || seen[c] {
+			t.Fatalf("Bad legacy MLB candidate %s", c)
+		}
+		seen[c] = true
+	}
+
+	s, err = parseSerial("C02LJ6QSFD56")
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates, err = s.MLBCandidates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range candidates {
+		if len(c) != 17 || !VerifyMLBChecksum(c) {
+			t.Fatalf("Bad MLB candidate %s", c)
+		}
+	}
+	// week symbol 0 can't be converted to a MLB week
+	s, err = decodeSerial("C02K03ECF8J2", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.MLB(); err == nil {
+		t.Fatal("Expected error for invalid week symbol")
+	}
+}
+
+func TestNormalizeSerial(t *testing.T) {
+	if s := normalizeSerial("c02l 13ec-f8j2"); s != "C02L13ECF8J2" {
+		t.Fatalf("Bad normalized serial %s", s)
+	}
+	// O and I are never valid serial symbols
+	if s := normalizeSerial("CO2LI3ECF8J2"); s != "C02L13ECF8J2" {
+		t.Fatalf("Bad normalized serial %s", s)
+	}
+}
+
+func TestRepairSerial(t *testing.T) {
+	// S typed instead of 5 in the model code
+	candidates := repairSerial("c02lj6qsfdS6")
+	if len(candidates) == 0 {
+		t.Fatal("No repair candidates")
+	}
+	if candidates[0].Serial != "C02LJ6QSFD56" {
+		t.Fatalf("Bad best candidate %s", candidates[0].Serial)
+	}
+	if candidates[0].Decoded.ProductName != "MacBookPro11,2" {
+		t.Fatal("Bad best candidate model")
+	}
+	// garbage can't be repaired
+	if len(repairSerial("123")) != 0 {
+		t.Fatal("Unexpected candidates for short serial")
+	}
+}
+
+func TestEnumerateSerials(t *testing.T) {
+	// no wildcards, only the serial itself
+	count, err := enumerateSerials("C02L13ECF8J2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("Bad count %d for a single serial", count)
+	}
+	// every base34 symbol is a valid last line symbol
+	var found []string
+	count, err = enumerateSerials("c02l13e?f8j2", func(s Serial) bool {
+		found = append(found, s.String())
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 34 || len(found) != 34 {
+		t.Fatalf("Bad count %d for line wildcard", count)
+	}
+	// unknown country
+	if _, err := enumerateSerials("ZZZL13ECF8J2", nil); err == nil {
+		t.Fatal("Expected error for unknown country")
+	}
+}
+
+func TestParseProductionWeek(t *testing.T) {
+	p, err := parseProductionWeek("2019-W10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Year != 2019 || p.Week != 10 {
+		t.Fatalf("Bad production week %s", p)
+	}
+	// 5 March is day 64 of the year, same week numbering as the serials
+	p, err = parseProductionWeek("2019-03-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Year != 2019 || p.Week != 10 {
+		t.Fatalf("Bad production week %s", p)
+	}
+	if _, err := parseProductionWeek("2019-W60"); err == nil {
+		t.Fatal("Expected error for invalid week")
+	}
+}
+
+func TestPickProductionWeek(t *testing.T) {
+	from := ProductionWeek{Year: 2019, Week: 10}
+	to := ProductionWeek{Year: 2020, Week: 30}
+	for i := 0; i < 100; i++ {
+		p, err := pickProductionWeek(iMacPro1_1, false, from, to, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.before(from) || to.before(p) {
+			t.Fatalf("Production week %s out of range", p)
+		}
+	}
+	// MacBook1,1 was only produced in 2006
+	if _, err := pickProductionWeek(MacBook1_1, true, from, to, nil); err == nil {
+		t.Fatal("Expected error for range outside model years")
+	}
+	if _, err := pickProductionWeek(iMacPro1_1, false, to, from, nil); err == nil {
+		t.Fatal("Expected error for reversed range")
+	}
+	// 31 December is in the last week, which is only picked when nothing else is left
+	last, err := parseProductionWeek("2019-12-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := pickProductionWeek(iMacPro1_1, false, last, last, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != last || p.Week != SERIAL_WEEK_MAX {
+		t.Fatalf("Bad production week %s", p)
+	}
+	for _, p := range productionWeeks(iMacPro1_1, false, ProductionWeek{Year: 2019, Week: 50}, last) {
+		if p.Week == SERIAL_WEEK_MAX {
+			t.Fatalf("Unexpected last week %s", p)
+		}
+	}
+	// without a model the range is clipped to the serial format years
+	for i := 0; i < 100; i++ {
+		p, err := pickProductionWeek(-1, true, ProductionWeek{Year: 2011, Week: 1}, ProductionWeek{Year: 2015, Week: 1}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Year > SERIAL_YEAR_OLD_MAX {
+			t.Fatalf("Production week %s out of legacy range", p)
+		}
+	}
+	if _, err := pickProductionWeek(-1, true, from, to, nil); err == nil {
+		t.Fatal("Expected error for range outside legacy years")
+	}
+	if _, err := pickProductionWeek(-1, false, ProductionWeek{Year: 2005, Week: 1}, ProductionWeek{Year: 2008, Week: 1}, nil); err == nil {
+		t.Fatal("Expected error for range outside modern years")
+	}
+}
+
+func TestPickCode(t *testing.T) {
+	codes := AppleModelCode[iMacPro1_1][:]
+	if pickCode(codes, CODE_PICK_FIRST, nil) != "HX87" {
+		t.Fatal("First code should always be picked")
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		for _, mode := range []int{CODE_PICK_UNIFORM, CODE_PICK_WEIGHTED} {
+			code := pickCode(codes, mode, nil)
+			if findCode(codes, code) < 0 {
+				t.Fatalf("Picked code %s is not a model code", code)
+			}
+			seen[code] = true
+		}
+	}
+	if len(seen) < 2 {
+		t.Fatal("Random code selection always picked the same code")
+	}
+}
+
+func TestGenerateSerialBoardCode(t *testing.T) {
+	args := Params{
+		Index:     iMacPro1_1,
+		Year:      -1,
+		Week:      -1,
+		Copy:      -1,
+		Line:      -1,
+		ModelCode: "JLD1",
+		BoardCode: "J80H",
+	}
+	s, err := generateSerial(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Model != "JLD1" {
+		t.Fatalf("Bad pinned model code %s", s.Model)
+	}
+	mlb, err := s.MLB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mlb[len(mlb)-6:len(mlb)-2] != "J80H" {
+		t.Fatalf("Bad pinned board code in MLB %s", mlb)
+	}
+}
+
+func TestValidateCountry(t *testing.T) {
+	if err := validateCountry("C02"); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateCountry("CK"); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateCountry("XXX"); err == nil {
+		t.Fatal("Expected error for unknown country")
+	}
+	if err := validateCountry("ZZ"); err == nil {
+		t.Fatal("Expected error for unknown legacy country")
+	}
+}
+
+func TestPickCountry(t *testing.T) {
+	if c := pickCountry(iMacPro1_1, COUNTRY_NEW_LEN, COUNTRY_PICK_BASE, nil); c != "C02" {
+		t.Fatalf("Bad base country %s", c)
+	}
+	countries, _ := plausibleCountries(iMacPro1_1, COUNTRY_NEW_LEN)
+	if len(countries) < 2 {
+		t.Fatal("Expected more than one plausible country")
+	}
+	for i := 0; i < 100; i++ {
+		c := pickCountry(iMacPro1_1, COUNTRY_NEW_LEN, COUNTRY_PICK_PLAUSIBLE, nil)
+		if findLocation(c) < 0 {
+			t.Fatalf("Picked unknown country %s", c)
+		}
+		c = pickCountry(-1, COUNTRY_OLD_LEN, COUNTRY_PICK_PLAUSIBLE, nil)
+		if findLocation(c) < 0 || c == REFURBISHED_LOCATION {
+			t.Fatalf("Picked invalid legacy country %s", c)
+		}
+	}
+}
+
+func BenchmarkDecodeSerial(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := decodeSerial(serials[i%len(serials)].serial, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenerateSerial(b *testing.B) {
+	args := Params{
+		Index: iMacPro1_1,
+		Year:  -1,
+		Week:  -1,
+		Copy:  -1,
+		Line:  -1,
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := generateSerial(args); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMLB(b *testing.B) {
+	s, err := decodeSerial("C02LJ6QSFD56", false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := s.MLB(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLegacyMLB(b *testing.B) {
+	s, err := decodeSerial("W88401231AX", false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := s.MLB(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGenerateBulk(t *testing.T) {
+	opts := BulkOptions{
+		Params: Params{
+			Year: -1,
+			Week: -1,
+			Copy: -1,
+			Line: -1,
+		},
+		Models: []int{iMacPro1_1, MacPro5_1},
+		Num:    200,
+		Unique: true,
+		Seed:   42,
+	}
+	var runs [2][]Identity
+	for i, workers := range []int{1, 3} {
+		opts.Workers = workers
+		stats, err := generateBulk(opts, func(id Identity) error {
+			runs[i] = append(runs[i], id)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Generated != 400 || len(runs[i]) != 400 {
+			t.Fatalf("Bad number of generated identities %d", stats.Generated)
+		}
+	}
+	// seeded output must not depend on the number of workers
+	seen := make(map[string]bool)
+	for i := range runs[0] {
+		if runs[0][i] != runs[1][i] {
+			t.Fatalf("Seeded output differs: %v vs %v", runs[0][i], runs[1][i])
+		}
+		id := runs[0][i]
+		if seen[id.Serial] || seen[id.MLB] || seen[id.ROM] {
+			t.Fatalf("Duplicate identity %v", id)
+		}
+		seen[id.Serial] = true
+		seen[id.MLB] = true
+		seen[id.ROM] = true
+	}
+}
+
+func TestBulkChunkSize(t *testing.T) {
+	if chunkSize(1) != 1 || chunkSize(BULK_CHUNK_SIZE*3) != BULK_CHUNK_SIZE {
+		t.Fatal("Bad chunk size")
+	}
+	// a small request is the start of a bigger one with the same seed
+	opts := BulkOptions{
+		Params:  Params{Year: -1, Week: -1, Copy: -1, Line: -1},
+		Models:  []int{iMacPro1_1},
+		Workers: 4,
+		Seed:    42,
+	}
+	var runs [2][]Identity
+	for i, num := range []int{5, 2000} {
+		opts.Num = num
+		if _, err := generateBulk(opts, func(id Identity) error {
+			runs[i] = append(runs[i], id)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range runs[0] {
+		if runs[0][i] != runs[1][i] {
+			t.Fatalf("Seeded output differs: %v vs %v", runs[0][i], runs[1][i])
+		}
+	}
+	err := exhaustedError(&opts, iMacPro1_1, 12904, 3, 900, 0)
+	if !strings.Contains(err.Error(), "MLB space") || !strings.Contains(err.Error(), "--profile realistic") {
+		t.Fatalf("Bad exhausted error %s", err)
+	}
+}
+
+func TestDerivativeSerials(t *testing.T) {
+	s, err := parseSerial("C02LJ6QSFD56")
+	if err != nil {
+		t.Fatal(err)
+	}
+	derivs := derivativeSerials(s, DefaultDerivOptions())
+	found := false
+	for _, d := range derivs {
+		info, err := decodeSerial(d.Serial, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// same production line, different copy
+		if info.DecodedLine != s.DecodedLine || info.DecodedCopy+1 != d.Copy {
+			t.Fatalf("Bad derivative %s", d.Serial)
+		}
+		if d.Original {
+			found = d.Serial == "C02LJ6QSFD56"
+		}
+	}
+	if !found {
+		t.Fatal("Original serial missing from derivatives")
+	}
+
+	opts := DefaultDerivOptions()
+	opts.ExcludeOriginal = true
+	opts.CopyMin, opts.CopyMax, err = parseCopyRange("2-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range derivativeSerials(s, opts) {
+		if d.Original || d.Copy < 2 || d.Copy > 3 {
+			t.Fatalf("Derivative %s not filtered", d.Serial)
+		}
+	}
+	if _, _, err := parseCopyRange("5-2"); err == nil {
+		t.Fatal("Expected error for reversed copy range")
+	}
+
+	// the normalization note must not end up in the JSON output
+	var out, notes bytes.Buffer
+	if err := printDerivatives(&out, &notes, "c02l13ecf8j2", DefaultDerivOptions(), true); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Derivative
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON output: %s", err)
+	}
+	if len(decoded) == 0 || !strings.Contains(notes.String(), "normalized to C02L13ECF8J2") {
+		t.Fatalf("Bad derivatives output %q, notes %q", out.String(), notes.String())
+	}
+}
+
+func TestCompareIdentities(t *testing.T) {
+	a, err := parseIdentity("C02LJ6QSFD56,C02443500KZG2QDA7,1C9E46106829")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// derivative serial, copy 2 of the same line
+	b, err := parseIdentity("c02lj3wsfd56,C02443500KZG2QDA7,1C9E46000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := compareIdentities(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SameSerial || !c.SameLine || !c.Derivative || c.WeekDistance != 0 || !c.SameModel {
+		t.Fatalf("Bad serial comparison %+v", c)
+	}
+	if !*c.SameMLB || *c.SameROM || !*c.SameROMOUI {
+		t.Fatalf("Bad identity comparison %+v", c)
+	}
+
+	// week 40 of 2008 vs week 27 of 2013
+	sa, _ := decodeSerial("C02L13ECF8J2", false)
+	sb, _ := decodeSerial("W88401231AX", false)
+	c = compareSerials(sa, sb)
+	if c.SameCountry || c.SameWeek || c.Derivative || c.WeekDistance != 248 {
+		t.Fatalf("Bad serial comparison %+v", c)
+	}
+	if _, err := parseIdentity("C02LJ6QSFD56,TOOSHORT"); err == nil {
+		t.Fatal("Expected error for invalid MLB")
+	}
+}
+
+func TestAnalyzeFleet(t *testing.T) {
+	input := strings.NewReader(`# cloned fleet
+iMacPro1,1 | Serial: C02LJ6QSFD56 | MLB: C02443500KZG2QDA7 | ROM: 1C9E46106829
+iMacPro1,1 | Serial: C02LJ3WSFD56 | MLB: C02443500KZG2QDA7 | ROM: 1C9E46106830
+C02LJ4USFD56,C02443500KZG2QDA7,1C9E46106831
+C02L13ECF8J2,,000000000001
+`)
+	ids, err := readIdentities(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 4 {
+		t.Fatalf("Bad number of identities %d", len(ids))
+	}
+	report := analyzeFleet(ids, 3)
+	if len(report.Derivatives) != 1 || len(report.Derivatives[0].Members) != 3 {
+		t.Fatalf("Bad derivatives %+v", report.Derivatives)
+	}
+	if len(report.Lines) != 1 || len(report.MLBBlocks) != 1 {
+		t.Fatalf("Bad clusters %+v %+v", report.Lines, report.MLBBlocks)
+	}
+	if len(report.ROMPrefixes) != 1 || report.ROMPrefixes[0].Key != "1C9E46" {
+		t.Fatalf("Bad ROM prefixes %+v", report.ROMPrefixes)
+	}
+	if len(report.NonAppleROM) != 1 {
+		t.Fatalf("Bad non Apple ROMs %+v", report.NonAppleROM)
+	}
+}
+
+func TestFleetGeneratorOutput(t *testing.T) {
+	// --generate-all output, the iMac11,2 MLB is not valid but the line is still read
+	input := strings.NewReader(`    MacBook1,1 | W86289Y1U9B | W862705P8V3GD
+    MacBook1,1 | W86247Y9U9B | W86230TK8V3G7
+      iMac11,2 | W81480LADB7 | W814705JEDCJN6
+    iMacPro1,1 | C02X30N6HX87 | C02829403OPJG36FB
+    iMacPro1,1 | C02XLNYMHX87 | C02843405J9JG36UE
+`)
+	ids, err := readIdentities(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 5 || ids[3].ProductName != "iMacPro1,1" || ids[3].Serial != "C02X30N6HX87" || ids[3].MLB != "C02829403OPJG36FB" {
+		t.Fatalf("Bad identities %+v", ids)
+	}
+	report := analyzeFleet(ids, 2)
+	if report.Total != 5 || len(report.Invalid) != 0 {
+		t.Fatalf("Bad report %+v", report)
+	}
+	if _, err := parseIdentityLine("iMacPro1,1 | C02X30N6HX87 | C02829403OPJG36FB | 1C9E46106829 | extra"); err == nil {
+		t.Fatal("Expected error for too many fields")
+	}
+}
+
+func TestFindNIC(t *testing.T) {
+	root := t.TempDir()
+	nics := []struct {
+		name, addr string
+		device     bool
+	}{
+		{"lo", "00:00:00:00:00:00", false},
+		{"docker0", "02:42:ac:11:00:02", false},
+		{"wlan0", "a6:8b:12:34:56:78", true},
+		{"eth1", "3c:07:54:00:11:22", true},
+		{"eth0", "3c:07:54:aa:bb:cc", true},
+	}
+	for _, n := range nics {
+		dir := filepath.Join(root, n.name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "address"), []byte(n.addr+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if n.device {
+			if err := os.Mkdir(filepath.Join(dir, "device"), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	nic, err := findNIC(root, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nic.Name != "eth0" || nic.ROM() != "3C0754AABBCC" || nic.ROMData() != "PAdUqrvM" {
+		t.Fatalf("Bad NIC %s %s %s", nic.Name, nic.ROM(), nic.ROMData())
+	}
+	if nic, err = findNIC(root, "eth1"); err != nil || nic.ROM() != "3C0754001122" {
+		t.Fatalf("Bad NIC %s %v", nic.ROM(), err)
+	}
+	for _, name := range []string{"lo", "docker0", "wlan0", "missing"} {
+		if _, err := findNIC(root, name); err == nil {
+			t.Fatalf("Interface %s should be rejected", name)
+		}
+	}
+	if _, err := findNIC(filepath.Join(root, "missing"), ""); err == nil {
+		t.Fatal("Missing root should fail")
+	}
+}
+
+func TestROMInfo(t *testing.T) {
+	for _, rom := range []string{"3C0754AABBCC", "3c:07:54:aa:bb:cc", "3C-07-54-AA-BB-CC", "PAdUqrvM"} {
+		info, err := romInfo(rom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ROM != "3C0754AABBCC" || !info.Apple || info.Local || info.Multicast || len(info.Warnings) != 0 {
+			t.Fatalf("Bad info for %s: %+v", rom, info)
+		}
+	}
+	info, err := romInfo("02:00:00:00:00:01")
+	if err != nil || !info.Local || info.Apple || len(info.Warnings) != 2 {
+		t.Fatalf("Bad info %+v %v", info, err)
+	}
+	info, err = romInfo("FFFFFFFFFFFF")
+	if err != nil || !info.Multicast || info.Warnings[0] != "ROM is the broadcast address" {
+		t.Fatalf("Bad info %+v %v", info, err)
+	}
+	for _, rom := range []string{"", "3C0754AABB", "3C0754AABBCCDD", "not a rom"} {
+		if _, err := romInfo(rom); err == nil {
+			t.Fatalf("ROM %s should be invalid", rom)
+		}
+	}
+}
+
+func TestLoadDatabase(t *testing.T) {
+	platform, codes := ApplePlatformData, AppleModelCode
+	t.Cleanup(saveTables())
+
+	bad := []string{
+		`{"models": [{"name": "Test1,1", "serial": "C02ZZ000ZZ9A"}]}`,
+		`{"models": [{"name": "Test1,1", "serial": "C02ZZ000ZZ9A", "cpu": "Intel Core i9-9880H", "model_codes": ["HX87"], "years": [2021]}]}`,
+		`{"models": [{"name": "Test1,1", "serial": "C02ZZ000ZZ9A", "cpu": "Intel Core i9-9880H", "model_codes": ["ZZ9"], "years": [2021]}]}`,
+		`{"models": [{"name": "Test1,1", "serial": "C02ZZ000ZZ9A", "cpu": "Intel Core i9-9880H", "model_codes": ["ZZ9A"], "years": [1999]}]}`,
+		`{"models": [{"name": "Test1,1", "serial": "C02ZZ000ZZ9A", "cpu": "Intel Core i9-9880H", "model_codes": ["ZZ9A"], "years": [2021], "preferred_year": 2022}]}`,
+		`{"models": [{"name": "Test1,1", "serial": "C02ZZ000ZZ9A", "cpu": "Intel Core i9-9880H", "model_codes": ["ZZ9A"], "board_codes": ["ZZB"], "years": [2021]}]}`,
+		`{"models": [{"name": "iMacPro1,1", "serial": "C02ZZ"}]}`,
+		`{"models": [{"name": "iMacPro1,1", "year": [2021]}]}`,
+	}
+	dir := t.TempDir()
+	for i, data := range bad {
+		path := filepath.Join(dir, "bad.json")
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadDatabase(path); err == nil {
+			t.Fatalf("Overlay %d should fail", i)
+		}
+		if len(ApplePlatformData) != len(platform) || ApplePlatformData[iMacPro1_1] != platform[iMacPro1_1] {
+			t.Fatalf("Failed overlay %d modified the tables", i)
+		}
+	}
+
+	path := filepath.Join(dir, "overlay.yaml")
+	overlay := `models:
+  - name: Test1,1
+    serial: C02ZZ000ZZ9A
+    model_codes: [ZZ9A]
+    board_codes: [ZZBD]
+    years: [2021]
+    cpu: Intel Core i9-9880H @ 2.30 GHz
+    smbios:
+      board_product: [Mac-0123456789ABCDEF]
+      chassis_type: 0x0D
+      firmware_features: 0x8FD8FF42E
+      smc_branch: [0x6A, 0x31, 0x33, 0x37, 0x00, 0x00, 0x00, 0x00]
+  - name: iMacPro1,1
+    board_codes: [K88F]
+descriptions:
+  - code: ZZ9A
+    name: Test (2021)
+`
+	if err := os.WriteFile(path, []byte(overlay), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadDatabase(path); err != nil {
+		t.Fatal(err)
+	}
+	index := len(platform)
+	if len(ApplePlatformData) != index+1 || ApplePlatformData[index].productName != "Test1,1" {
+		t.Fatalf("Model not added")
+	}
+	if cpu := modelCPU(AppleModel(index)); cpu.Arch != "Coffee Lake" || cpu.Generation != 9 || !cpu.Mobile {
+		t.Fatalf("Bad CPU %+v", cpu)
+	}
+	smbios := modelSMBIOS(AppleModel(index))
+	if smbios.BoardID() != "Mac-0123456789ABCDEF" || smbios.ChassisType != 0x0D || smbios.FirmwareFeatures != 0x8FD8FF42E {
+		t.Fatalf("Bad SMBIOS %+v", smbios)
+	}
+	if AppleBoardCode[iMacPro1_1][0] != "K88F" || AppleBoardCode[iMacPro1_1][1] != "" || AppleModelCode[iMacPro1_1] != codes[iMacPro1_1] {
+		t.Fatalf("Model not replaced %v", AppleBoardCode[iMacPro1_1][:2])
+	}
+	s, err := parseSerial("C02FP1YRZZ9A")
+	if err != nil || s.index != index || s.ModelDesc != "Test (2021)" {
+		t.Fatalf("Bad decoded serial %+v %v", s, err)
+	}
+	s, err = generateSerial(Params{Index: index, Year: -1, Week: -1, Copy: -1, Line: -1})
+	if err != nil || !strings.HasSuffix(s.String(), "ZZ9A") {
+		t.Fatalf("Bad generated serial %s %v", s.String(), err)
+	}
+	if mlb, err := s.MLB(); err != nil || len(mlb) != MLB_NEW_LEN || !strings.Contains(mlb, "ZZBD") {
+		t.Fatalf("Bad MLB %s %v", mlb, err)
+	}
+}
+
+// base serials shipped by upstream macserial that don't pass parseSerial
+var knownBadBaseSerials = map[string]string{
+	"MacBook1,1":     "W80A041AU9B",
+	"MacBook2,1":     "W88A041AWGP",
+	"MacBook4,1":     "W88A041A0P0",
+	"MacBook5,2":     "W88AAAAA9GU",
+	"MacBook6,1":     "451131JCGAY",
+	"MacBookAir1,1":  "W864947A18X",
+	"MacBookAir2,1":  "W86494769A7",
+	"MacBookAir4,1":  "C02KGHACDJY8",
+	"MacBookAir6,2":  "C02HACKUF5V7",
+	"MacBookPro1,1":  "W884857JVJ1",
+	"MacBookPro11,1": "C02LSHACFH00",
+	"MacBookPro11,2": "C02LSHACG86R",
+	"MacBookPro11,3": "C02LSHACFR1M",
+	"MacBookPro11,5": "C02LSHACG85Y",
+	"MacBookPro12,1": "C02Q51OSH1DP",
+	"MacBookPro2,1":  "W88130WUW0H",
+	"MacBookPro2,2":  "W8827B4CW0L",
+	"MacBookPro3,1":  "W8841OHZX91",
+	"MacBookPro9,2":  "C02HA041DTY3",
+	"MacPro1,1":      "W88A7HACUQ2",
+	"MacPro2,1":      "W8930518UPZ",
+	"MacPro3,1":      "W88A77AA5J4",
+	"MacPro6,1":      "F5KLA770F9VM",
+	"Macmini5,1":     "C07GA041DJD0",
+	"iMac10,1":       "W80AA98A5PE",
+	"iMac12,1":       "W80CF65ADHJF",
+	"iMac13,1":       "C02JA041DNCT",
+	"iMac13,2":       "C02JB041DNCW",
+	"iMac14,4":       "D25LHACKFY0T",
+	"iMac8,1":        "W8755HAC2E2",
+	"iMac9,1":        "W89A00A36MJ",
+}
+
+// checkDatabaseProblems returns the checkDatabase problems that are not known upstream bad base serials
+func checkDatabaseProblems() []string {
+	var ret []string
+	for _, p := range checkDatabase() {
+		known := false
+		for name, serial := range knownBadBaseSerials {
+			if strings.HasPrefix(p, name+" base serial "+serial+" is not valid") {
+				known = true
+				break
+			}
+		}
+		if !known {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+func TestCheckDatabase(t *testing.T) {
+	for _, p := range checkDatabaseProblems() {
+		t.Error(p)
+	}
+	if len(checkDatabase()) != len(knownBadBaseSerials) {
+		t.Errorf("Expected %d bad base serials, got %v", len(knownBadBaseSerials), checkDatabase())
+	}
+
+	// a base serial with an invalid week still decodes without error
+	platforms := ApplePlatformData
+	t.Cleanup(func() { ApplePlatformData = platforms })
+	ApplePlatformData = append([]PlatformData(nil), platforms...)
+	ApplePlatformData[iMacPro1_1].serialNumber = "C02TA041HX87"
+	if problems := checkDatabaseProblems(); len(problems) != 1 || !strings.Contains(problems[0], "Invalid week symbol 'A'") {
+		t.Fatalf("Bad problems %v", problems)
+	}
+	ApplePlatformData = platforms
+
+	preferred := ApplePreferredModelYear
+	t.Cleanup(func() { ApplePreferredModelYear = preferred })
+	ApplePreferredModelYear = append([]uint32(nil), preferred...)
+	ApplePreferredModelYear[iMacPro1_1] = 2001
+	if problems := checkDatabaseProblems(); len(problems) != 1 || !strings.Contains(problems[0], "preferred year 2001") {
+		t.Fatalf("Bad problems %v", problems)
+	}
+	ApplePreferredModelYear = ApplePreferredModelYear[:len(ApplePreferredModelYear)-1]
+	if problems := checkDatabase(); len(problems) != 1 || !strings.Contains(problems[0], "ApplePreferredModelYear") {
+		t.Fatalf("Bad problems %v", problems)
+	}
+}
+
+const testGeneratedDB = `package main
+
+var ApplePlatformData = []PlatformData{
+  { "MacPro1,1", "W88A7HACUQ2" },
+  { "iMac1,1", "W8031AAAAAA" },
+}
+
+var AppleModelCode = [][APPLE_MODEL_CODE_MAX]string{
+  /* MacPro1,1      */ {"UQ2", "UPZ"},
+  /* iMac1,1        */ {"AAA"},
+}
+
+var AppleBoardCode = [][APPLE_BOARD_CODE_MAX]string{
+  /* MacPro1,1      */ {"4H1"},
+  /* iMac1,1        */ {""},
+}
+
+var AppleModelYear = [][APPLE_MODEL_YEAR_MAX]uint32{
+  /* MacPro1,1      */ {2006, 2007},
+  /* iMac1,1        */ {2007},
+}
+
+var ApplePreferredModelYear = []uint32{
+  /* MacPro1,1      */ 0,
+  /* iMac1,1        */ 2007,
+}
+
+var AppleSMBIOSInfo = []SMBIOSInfo{
+  /* MacPro1,1      */ {BoardProduct: []string{"Mac-F4208DC8"}, ChassisType: 0x07, FirmwareFeatures: 0xC0001403, SmcBranch: []int{0x6D, 0x34, 0x33, 0x00}},
+  /* iMac1,1        */ {},
+}
+
+var AppleModelDesc = []AppleModelDescription{
+ {"AAA", "iMac"},
+ {"UQ2", "Mac Pro"},
+}
+`
+
+func TestDiffDB(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "modelinfo_autogen.go")
+	if err := os.WriteFile(path, []byte(testGeneratedDB), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := readDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smbios := a.Models[0].SMBIOS
+	if smbios.BoardID() != "Mac-F4208DC8" || smbios.ChassisType != 7 || smbios.FirmwareFeatures != 0xC0001403 || smcBytesString(smbios.SmcBranch) != "m43" {
+		t.Fatalf("Bad generated SMBIOS %+v", smbios)
+	}
+	if len(a.Models) != 2 || a.Models[1].Preferred != 2007 || len(a.Models[1].BoardCodes) != 0 || a.Desc["UQ2"] != "Mac Pro" {
+		t.Fatalf("Bad generated database %+v", a)
+	}
+	b := ModelDB{Models: []DBModel{a.Models[0]}, Desc: map[string]string{"UQ2": "Mac Pro (2006)", "UPZ": "Mac Pro"}}
+	b.Models[0].ModelCodes = []string{"UQ2", "UQ3"}
+	b.Models[0].Years = []uint32{2006}
+	b.Models[0].SMBIOS.ChassisType = 0
+	b.Models[0].SMBIOS.BIOSVersion = "MP11.88Z"
+	diff := diffDB(a, b)
+	if len(diff.AddedModels) != 0 || len(diff.RemovedModels) != 1 || diff.RemovedModels[0] != "iMac1,1" {
+		t.Fatalf("Bad models diff %+v", diff)
+	}
+	if len(diff.ChangedModels) != 1 {
+		t.Fatalf("Bad changed models %+v", diff.ChangedModels)
+	}
+	c := diff.ChangedModels[0]
+	if strings.Join(c.AddedModelCodes, ",") != "UQ3" || strings.Join(c.RemovedModelCodes, ",") != "UPZ" || len(c.NewYears) != 1 || c.OldPreferred != nil ||
+		strings.Join(c.SMBIOSChanged, ",") != "BIOS Version,Chassis Type" {
+		t.Fatalf("Bad model change %+v", c)
+	}
+	if len(diff.AddedDesc) != 1 || len(diff.RemovedDesc) != 1 || len(diff.ChangedDesc) != 1 || diff.ChangedDesc[0].New != "Mac Pro (2006)" {
+		t.Fatalf("Bad descriptions diff %+v", diff)
+	}
+	if d := diffDB(a, a); !d.Empty() {
+		t.Fatalf("Same database should have no differences %+v", d)
+	}
+
+	overlay := filepath.Join(dir, "overlay.json")
+	if err := os.WriteFile(overlay, []byte(`{"models": [{"name": "iMacPro1,1", "preferred_year": 2018}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err = readDB(overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ApplePreferredModelYear[iMacPro1_1] != 0 {
+		t.Fatal("Overlay diff modified the tables")
+	}
+	diff = diffDB(currentDB(), b)
+	if len(diff.ChangedModels) != 1 || *diff.ChangedModels[0].NewPreferred != 2018 || len(diff.AddedDesc) != 0 {
+		t.Fatalf("Bad overlay diff %+v", diff)
+	}
+}
+
+func TestSMBIOSInfo(t *testing.T) {
+	if smcBytesString([]int{0x6A, 0x31, 0x33, 0x37, 0, 0, 0, 0}) != "j137" {
+		t.Fatal("Bad SMC text")
+	}
+	if smcBytesString([]int{0x02, 0x41, 0x0F, 0, 0, 0x10}) != "02410F000010" {
+		t.Fatal("Bad SMC data")
+	}
+	info := SMBIOSInfo{BoardProduct: []string{"Mac-7BA5B2D9E42DDD94"}, ChassisType: 0x0D}
+	if info.Empty() || info.BoardID() != "Mac-7BA5B2D9E42DDD94" || len(info.fields()) != 2 || info.fields()[1][1] != "0x0D" {
+		t.Fatalf("Bad SMBIOS fields %v", info.fields())
+	}
+	if m := modelSMBIOS(AppleModel(len(ApplePlatformData))); !m.Empty() {
+		t.Fatal("Unknown model should have no SMBIOS values")
+	}
+}
+
+func TestBoardID(t *testing.T) {
+	t.Cleanup(saveTables())
+	shared := &SMBIOSInfo{BoardProduct: []string{"Mac-F221BEC8"}, BIOSVersion: "MP51.88Z"}
+	overlay := &Overlay{Models: []OverlayModel{
+		{Name: "MacPro4,1", SMBIOS: shared},
+		{Name: "MacPro5,1", SMBIOS: &SMBIOSInfo{BoardProduct: []string{"Mac-F221BEC8", "Mac-F4208DC8"}}},
+		{Name: "iMacPro1,1", SMBIOS: &SMBIOSInfo{BoardProduct: []string{"Mac-7BA5B2D9E42DDD94"}}},
+	}}
+	if err := mergeOverlay(overlay); err != nil {
+		t.Fatal(err)
+	}
+	if models := findBoardID("mac-7ba5b2d9e42ddd94"); len(models) != 1 || models[0] != iMacPro1_1 {
+		t.Fatalf("Bad board-id models %v", models)
+	}
+	if models := findBoardID("Mac-F221BEC8"); len(models) != 2 || models[0] != MacPro4_1 || models[1] != MacPro5_1 {
+		t.Fatalf("Bad shared board-id models %v", models)
+	}
+	if models := findBoardID("Mac-00000000"); len(models) != 0 {
+		t.Fatalf("Unknown board-id matched %v", models)
+	}
+	if values, err := showSMBIOSField(MacPro5_1, "board-id"); err != nil || strings.Join(values, ",") != "Mac-F221BEC8,Mac-F4208DC8" {
+		t.Fatalf("Bad board-id values %v %v", values, err)
+	}
+	if values, err := showSMBIOSField(MacPro4_1, "BIOS-Version"); err != nil || values[0] != "MP51.88Z" {
+		t.Fatalf("Bad BIOS version %v %v", values, err)
+	}
+	if _, err := showSMBIOSField(MacPro4_1, "smc-branch"); err == nil {
+		t.Fatal("Unknown value should fail")
+	}
+	if _, err := showSMBIOSField(MacPro4_1, "serial-number"); err == nil {
+		t.Fatal("Invalid value name should fail")
+	}
+}
+
+func TestMacOSSupport(t *testing.T) {
+	t.Cleanup(saveTables())
+	for _, v := range []string{"", "13.x", "1.2.3.4", "-1"} {
+		if _, err := parseMacOSVersion(v); err == nil {
+			t.Fatalf("Version %q should be invalid", v)
+		}
+	}
+	tests := []struct {
+		support   MacOSSupport
+		version   string
+		supported bool
+		known     bool
+	}{
+		{MacOSSupport{}, "13", false, false},
+		{MacOSSupport{Min: "10.14.4"}, "13.0", true, true},
+		{MacOSSupport{Min: "10.14.4"}, "10.14", true, true},
+		{MacOSSupport{Min: "10.14.4"}, "10.13.6", false, true},
+		{MacOSSupport{Min: "10.6.4", Max: "10.14.6"}, "10.14", true, true},
+		{MacOSSupport{Min: "10.6.4", Max: "10.14"}, "10.14.6", true, true},
+		{MacOSSupport{Min: "10.13", Max: "12"}, "12.6", true, true},
+		{MacOSSupport{Min: "10.13", Max: "12.6"}, "12.7", false, true},
+		{MacOSSupport{Min: "10.6.4", Max: "10.14"}, "11", false, true},
+	}
+	for _, test := range tests {
+		version, err := parseMacOSVersion(test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if supported, known := test.support.Supports(version); supported != test.supported || known != test.known {
+			t.Fatalf("%s on %s: got %v %v", test.version, test.support.String(), supported, known)
+		}
+	}
+	if err := validateMacOSSupport(MacOSSupport{Min: "13.2", Max: "13"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateMacOSSupport(MacOSSupport{Min: "13", Max: "12.6"}); err == nil {
+		t.Fatal("Minimum newer than maximum should fail")
+	}
+
+	min, empty := "12.6", ""
+	if err := mergeOverlay(&Overlay{Models: []OverlayModel{{Name: "iMac19,1", MinMacOS: &min, MaxMacOS: &empty}}}); err != nil {
+		t.Fatal(err)
+	}
+	if w := macOSWarning(iMac19_1, "12", []int{12}); w != "" {
+		t.Fatalf("Unexpected warning %s", w)
+	}
+	if w := macOSWarning(iMac19_1, "11", []int{11}); !strings.Contains(w, "requires macOS 12.6") {
+		t.Fatalf("Bad warning %s", w)
+	}
+	if w := macOSWarning(iMacPro1_1, "13", []int{13}); !strings.Contains(w, "unknown") {
+		t.Fatalf("Bad warning %s", w)
+	}
+	version := "13.x"
+	if err := mergeOverlay(&Overlay{Models: []OverlayModel{{Name: "iMac19,1", MaxMacOS: &version}}}); err == nil {
+		t.Fatal("Invalid maximum version should fail")
+	}
+	if modelMacOS(iMac19_1).Min != "12.6" || len(checkDatabaseProblems()) != 0 {
+		t.Fatalf("Failed overlay modified the tables %v", checkDatabaseProblems())
+	}
+}
+
+const testPlatformSupport = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>SupportedBoardIds</key>
+	<array>
+		<string>Mac-7BA5B2D9E42DDD94</string>
+	</array>
+	<key>Nested</key>
+	<dict>
+		<key>SupportedModelProperties</key>
+		<array>
+			<string>MacPro5,1</string>
+		</array>
+	</dict>
+	<key>SupportedModelProperties</key>
+	<array>
+		<string>iMac19,1</string>
+	</array>
+</dict>
+</plist>
+`
+
+func TestPlatformSupport(t *testing.T) {
+	t.Cleanup(saveTables())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "PlatformSupport.plist")
+	if err := os.WriteFile(path, []byte(testPlatformSupport), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ps, err := readPlatformSupport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps.BoardIDs) != 1 || len(ps.Models) != 1 || ps.Models[0] != "iMac19,1" {
+		t.Fatalf("Bad platform support %+v", ps)
+	}
+	if err := mergeOverlay(&Overlay{Models: []OverlayModel{{Name: "iMacPro1,1", SMBIOS: &SMBIOSInfo{BoardProduct: []string{"Mac-7BA5B2D9E42DDD94"}}}}}); err != nil {
+		t.Fatal(err)
+	}
+	c := checkSupport(ps, iMacPro1_1)
+	if !c.Supported || c.ModelSupported || c.BoardIDMatch != "Mac-7BA5B2D9E42DDD94" {
+		t.Fatalf("Bad board-id check %+v", c)
+	}
+	if c = checkSupport(ps, iMac19_1); !c.Supported || !c.ModelSupported || c.BoardIDMatch != "" {
+		t.Fatalf("Bad product name check %+v", c)
+	}
+	// the installer lists board-ids but MacPro5,1 has none to check
+	if c = checkSupport(ps, MacPro5_1); c.Supported || !c.BoardIDUnknown {
+		t.Fatalf("Nested dictionary should be ignored %+v", c)
+	}
+	if err := mergeOverlay(&Overlay{Models: []OverlayModel{{Name: "MacPro5,1", SMBIOS: &SMBIOSInfo{BoardProduct: []string{"Mac-F221BEC8"}}}}}); err != nil {
+		t.Fatal(err)
+	}
+	if c = checkSupport(ps, MacPro5_1); c.Supported || c.BoardIDUnknown {
+		t.Fatalf("Bad unsupported board-id check %+v", c)
+	}
+	if c = checkSupport(ps, -1); c.Model != "" || strings.Join(c.SupportedModels, ",") != "iMac19,1,iMacPro1,1" {
+		t.Fatalf("Bad supported models %v", c.SupportedModels)
+	}
+
+	if err := os.WriteFile(path, []byte("bplist00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPlatformSupport(path); err == nil || !strings.Contains(err.Error(), "plutil") {
+		t.Fatalf("Binary plist should fail: %v", err)
+	}
+}
+
+func TestParseCPU(t *testing.T) {
+	tests := []struct {
+		name       string
+		number     string
+		generation int
+		arch       string
+		mobile     bool
+	}{
+		{"Intel Core 2 Duo T7400 @ 2.16 GHz", "T7400", 0, "Merom", true},
+		{"Intel Core 2 Duo E8435 @ 3.06 GHz", "E8435", 0, "Penryn", false},
+		{"Intel Core i5-750 @ 2.66 GHz", "750", 1, "Nehalem", false},
+		{"Intel Core i5-520M @ 2.40 GHz", "520M", 1, "Westmere", true},
+		{"Intel Core M 5Y51 @ 1.10 GHz", "5Y51", 5, "Broadwell", true},
+		{"Intel Core m3-7Y32 @ 1.10 GHz", "7Y32", 7, "Kaby Lake", true},
+		{"Intel Core i5-8210Y @ 1.60 GHz", "8210Y", 8, "Amber Lake", true},
+		{"Intel(R) Core(TM) i7-4790k CPU @ 4.00GHz", "4790K", 4, "Haswell", false},
+		{"Intel Core i5-1038NG7 @ 2.00 GHz", "1038NG7", 10, "Ice Lake", true},
+		{"Intel Core i9-10910 @ 3.60 GHz", "10910", 10, "Comet Lake", false},
+		{"11th Gen Intel(R) Core(TM) i7-1165G7 @ 2.80GHz", "1165G7", 11, "Tiger Lake", true},
+		{"Intel Xeon E5462 x2 @ 2.80 GHz", "E5462", 0, "Penryn", false},
+		{"Intel Xeon W3530 @ 2.80 GHz", "W3530", 1, "Nehalem", false},
+		{"Intel(R) Xeon(R) CPU E5-2680 v2 @ 2.80GHz", "E5-2680 v2", 3, "Ivy Bridge", false},
+		{"Intel Xeon W-3245M CPU @ 3.20 GHz", "W-3245", 0, "Cascade Lake", false},
+		{"AMD Ryzen 7 5800H with Radeon Graphics", "", 0, "", true},
+	}
+	for _, test := range tests {
+		c := parseCPU(test.name)
+		if c.Number != test.number || c.Generation != test.generation || c.Arch != test.arch || c.Mobile != test.mobile {
+			t.Fatalf("Bad CPU %s: %+v", test.name, c)
+		}
+	}
+	for i := range AppleModelCPU {
+		if c := modelCPU(AppleModel(i)); c.Vendor != "Intel" || c.Arch == "" {
+			t.Fatalf("Unknown CPU %s of %s", AppleModelCPU[i], ApplePlatformData[i].productName)
+		}
+	}
+}
+
+func TestRecommendModels(t *testing.T) {
+	t.Cleanup(saveTables())
+	path := filepath.Join(t.TempDir(), "cpuinfo")
+	cpuinfo := "processor\t: 0\nvendor_id\t: GenuineIntel\nmodel name\t: Intel(R) Core(TM) i7-8700K CPU @ 3.70GHz\n\nprocessor\t: 1\n"
+	if err := os.WriteFile(path, []byte(cpuinfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	name, err := hostCPU(path)
+	if err != nil || name != "Intel(R) Core(TM) i7-8700K CPU @ 3.70GHz" {
+		t.Fatalf("Bad host CPU %s %v", name, err)
+	}
+
+	r := recommendModels(parseCPU(name), "", nil)
+	if len(r.Models) == 0 || r.Models[0].Score != 103 || modelCPU(iMac19_1).Arch != r.CPU.Arch {
+		t.Fatalf("Bad recommendation %+v", r.Models[0])
+	}
+	for _, m := range r.Models {
+		if strings.HasPrefix(m.Model, "MacBook") {
+			t.Fatalf("Laptop %s recommended for a desktop CPU", m.Model)
+		}
+	}
+
+	min, max := "10.14.4", ""
+	if err := mergeOverlay(&Overlay{Models: []OverlayModel{{Name: "Macmini8,1", MinMacOS: &min, MaxMacOS: &max}}}); err != nil {
+		t.Fatal(err)
+	}
+	r = recommendModels(parseCPU(name), "10.13", []int{10, 13})
+	for _, m := range r.Models {
+		if m.Model == "Macmini8,1" {
+			t.Fatal("Unsupported model recommended")
+		}
+	}
+	r = recommendModels(parseCPU(name), "", nil)
+	if r.Models[0].Model != "Macmini8,1" || r.Models[0].Score != 108 {
+		t.Fatalf("Supported model should be first %+v", r.Models[0])
+	}
+
+	r = recommendModels(parseCPU("Intel Core i7-8750H"), "", nil)
+	if len(r.Models) == 0 || !strings.HasPrefix(r.Models[0].Model, "MacBookPro15,") {
+		t.Fatalf("Bad laptop recommendation %+v", r.Models)
+	}
+}
+
+func TestListModels(t *testing.T) {
+	if modelFamily("MacBookPro15,1") != "MacBookPro" || modelFamily("iMacPro1,1") != "iMacPro" {
+		t.Fatal("Bad model family")
+	}
+	all := listModels(ListFilter{Year: -1})
+	if len(all) != len(ApplePlatformData) {
+		t.Fatalf("Empty filter listed %d models", len(all))
+	}
+	models := listModels(ListFilter{Family: "macbookpro", Year: 2019})
+	if len(models) == 0 {
+		t.Fatal("No MacBookPro from 2019")
+	}
+	for _, m := range models {
+		if !strings.HasPrefix(m.Model, "MacBookPro") || !modelHasYear(AppleModel(m.Index), 2019) {
+			t.Fatalf("Bad filtered model %s", m.Model)
+		}
+	}
+	models = listModels(ListFilter{Year: -1, Code: "hx87"})
+	if len(models) != 1 || models[0].Index != iMacPro1_1 || len(models[0].Matches) != 1 || models[0].Matches[0].Code != "HX87" {
+		t.Fatalf("Bad model code owner %+v", models)
+	}
+	board := AppleBoardCode[iMacPro1_1][1]
+	if models = listModels(ListFilter{Year: -1, BoardCode: board}); len(models) == 0 || models[0].Index != iMacPro1_1 {
+		t.Fatalf("Bad board code owner %+v", models)
+	}
+	models = listModels(ListFilter{Year: -1, Search: "retina 5k"})
+	if len(models) == 0 {
+		t.Fatal("No Retina 5K models")
+	}
+	for _, m := range models {
+		if len(m.Matches) == 0 || !strings.Contains(m.Matches[0].Name, "Retina 5K") {
+			t.Fatalf("Bad search match %+v", m)
+		}
+	}
+	if models = listModels(ListFilter{Year: -1, Search: "imacpro"}); len(models) != 1 || models[0].Index != iMacPro1_1 {
+		t.Fatalf("Product name search failed %+v", models)
+	}
+	if models = listModels(ListFilter{Family: "iMac", Year: -1, Code: "HX87"}); len(models) != 0 {
+		t.Fatalf("Filters should be combined %+v", models)
+	}
+}
+
+func TestProducts(t *testing.T) {
+	p, err := lookupProduct(" hx87")
+	if err != nil || p.Name != "iMac Pro (2017)" || !p.Usable || len(p.Models) != 1 || p.Models[0] != "iMacPro1,1" {
+		t.Fatalf("Bad product %+v %v", p, err)
+	}
+	if _, err := lookupProduct("ZZZZ"); err == nil {
+		t.Fatal("Unknown product code should fail")
+	}
+	if _, err := lookupProduct("HX8"); err == nil {
+		t.Fatal("Unknown 3 character product code should fail")
+	}
+	if _, err := lookupProduct("HX870"); err == nil {
+		t.Fatal("Invalid product code length should fail")
+	}
+
+	products := searchProducts("retina 5k")
+	if len(products) == 0 {
+		t.Fatal("No Retina 5K products")
+	}
+	for _, p := range products {
+		if !strings.Contains(p.Name, "Retina 5K") || p.Usable != (len(p.Models) > 0) {
+			t.Fatalf("Bad search result %+v", p)
+		}
+	}
+	// descriptions without a model in the database can't be used for generation
+	unusable := 0
+	for _, p := range searchProducts("") {
+		if !p.Usable {
+			unusable++
+			if _, err := lookupProduct(p.Code); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if unusable == 0 {
+		t.Fatal("Every product code is owned by a model")
+	}
 }
@@ -751,6 +751,378 @@ var ApplePreferredModelYear = []uint32{
   /* iMacPro1,1     */ 0,
 }
 
+var AppleSMBIOSInfo = []SMBIOSInfo{
+  /* MacBook1,1     */ {},
+  /* MacBook10,1    */ {},
+  /* MacBook2,1     */ {},
+  /* MacBook3,1     */ {},
+  /* MacBook4,1     */ {},
+  /* MacBook5,1     */ {},
+  /* MacBook5,2     */ {},
+  /* MacBook6,1     */ {},
+  /* MacBook7,1     */ {},
+  /* MacBook8,1     */ {},
+  /* MacBook9,1     */ {},
+  /* MacBookAir1,1  */ {},
+  /* MacBookAir2,1  */ {},
+  /* MacBookAir3,1  */ {},
+  /* MacBookAir3,2  */ {},
+  /* MacBookAir4,1  */ {},
+  /* MacBookAir4,2  */ {},
+  /* MacBookAir5,1  */ {},
+  /* MacBookAir5,2  */ {},
+  /* MacBookAir6,1  */ {},
+  /* MacBookAir6,2  */ {},
+  /* MacBookAir7,1  */ {},
+  /* MacBookAir7,2  */ {},
+  /* MacBookAir8,1  */ {},
+  /* MacBookAir8,2  */ {},
+  /* MacBookAir9,1  */ {},
+  /* MacBookPro1,1  */ {},
+  /* MacBookPro1,2  */ {},
+  /* MacBookPro10,1 */ {},
+  /* MacBookPro10,2 */ {},
+  /* MacBookPro11,1 */ {},
+  /* MacBookPro11,2 */ {},
+  /* MacBookPro11,3 */ {},
+  /* MacBookPro11,4 */ {},
+  /* MacBookPro11,5 */ {},
+  /* MacBookPro12,1 */ {},
+  /* MacBookPro13,1 */ {},
+  /* MacBookPro13,2 */ {},
+  /* MacBookPro13,3 */ {},
+  /* MacBookPro14,1 */ {},
+  /* MacBookPro14,2 */ {},
+  /* MacBookPro14,3 */ {},
+  /* MacBookPro15,1 */ {},
+  /* MacBookPro15,2 */ {},
+  /* MacBookPro15,3 */ {},
+  /* MacBookPro15,4 */ {},
+  /* MacBookPro16,1 */ {},
+  /* MacBookPro16,2 */ {},
+  /* MacBookPro16,3 */ {},
+  /* MacBookPro16,4 */ {},
+  /* MacBookPro2,1  */ {},
+  /* MacBookPro2,2  */ {},
+  /* MacBookPro3,1  */ {},
+  /* MacBookPro4,1  */ {},
+  /* MacBookPro5,1  */ {},
+  /* MacBookPro5,2  */ {},
+  /* MacBookPro5,3  */ {},
+  /* MacBookPro5,4  */ {},
+  /* MacBookPro5,5  */ {},
+  /* MacBookPro6,1  */ {},
+  /* MacBookPro6,2  */ {},
+  /* MacBookPro7,1  */ {},
+  /* MacBookPro8,1  */ {},
+  /* MacBookPro8,2  */ {},
+  /* MacBookPro8,3  */ {},
+  /* MacBookPro9,1  */ {},
+  /* MacBookPro9,2  */ {},
+  /* MacPro1,1      */ {},
+  /* MacPro2,1      */ {},
+  /* MacPro3,1      */ {},
+  /* MacPro4,1      */ {},
+  /* MacPro5,1      */ {},
+  /* MacPro6,1      */ {},
+  /* MacPro7,1      */ {},
+  /* Macmini1,1     */ {},
+  /* Macmini2,1     */ {},
+  /* Macmini3,1     */ {},
+  /* Macmini4,1     */ {},
+  /* Macmini5,1     */ {},
+  /* Macmini5,2     */ {},
+  /* Macmini5,3     */ {},
+  /* Macmini6,1     */ {},
+  /* Macmini6,2     */ {},
+  /* Macmini7,1     */ {},
+  /* Macmini8,1     */ {},
+  /* Xserve1,1      */ {},
+  /* Xserve2,1      */ {},
+  /* Xserve3,1      */ {},
+  /* iMac10,1       */ {},
+  /* iMac11,1       */ {},
+  /* iMac11,2       */ {},
+  /* iMac11,3       */ {},
+  /* iMac12,1       */ {},
+  /* iMac12,2       */ {},
+  /* iMac13,1       */ {},
+  /* iMac13,2       */ {},
+  /* iMac13,3       */ {},
+  /* iMac14,1       */ {},
+  /* iMac14,2       */ {},
+  /* iMac14,3       */ {},
+  /* iMac14,4       */ {},
+  /* iMac15,1       */ {},
+  /* iMac16,1       */ {},
+  /* iMac16,2       */ {},
+  /* iMac17,1       */ {},
+  /* iMac18,1       */ {},
+  /* iMac18,2       */ {},
+  /* iMac18,3       */ {},
+  /* iMac19,1       */ {},
+  /* iMac19,2       */ {},
+  /* iMac20,1       */ {},
+  /* iMac20,2       */ {},
+  /* iMac4,1        */ {},
+  /* iMac4,2        */ {},
+  /* iMac5,1        */ {},
+  /* iMac5,2        */ {},
+  /* iMac6,1        */ {},
+  /* iMac7,1        */ {},
+  /* iMac8,1        */ {},
+  /* iMac9,1        */ {},
+  /* iMacPro1,1     */ {},
+}
+
+var AppleMacOSSupport = []MacOSSupport{
+  /* MacBook1,1     */ {},
+  /* MacBook10,1    */ {},
+  /* MacBook2,1     */ {},
+  /* MacBook3,1     */ {},
+  /* MacBook4,1     */ {},
+  /* MacBook5,1     */ {},
+  /* MacBook5,2     */ {},
+  /* MacBook6,1     */ {},
+  /* MacBook7,1     */ {},
+  /* MacBook8,1     */ {},
+  /* MacBook9,1     */ {},
+  /* MacBookAir1,1  */ {},
+  /* MacBookAir2,1  */ {},
+  /* MacBookAir3,1  */ {},
+  /* MacBookAir3,2  */ {},
+  /* MacBookAir4,1  */ {},
+  /* MacBookAir4,2  */ {},
+  /* MacBookAir5,1  */ {},
+  /* MacBookAir5,2  */ {},
+  /* MacBookAir6,1  */ {},
+  /* MacBookAir6,2  */ {},
+  /* MacBookAir7,1  */ {},
+  /* MacBookAir7,2  */ {},
+  /* MacBookAir8,1  */ {},
+  /* MacBookAir8,2  */ {},
+  /* MacBookAir9,1  */ {},
+  /* MacBookPro1,1  */ {},
+  /* MacBookPro1,2  */ {},
+  /* MacBookPro10,1 */ {},
+  /* MacBookPro10,2 */ {},
+  /* MacBookPro11,1 */ {},
+  /* MacBookPro11,2 */ {},
+  /* MacBookPro11,3 */ {},
+  /* MacBookPro11,4 */ {},
+  /* MacBookPro11,5 */ {},
+  /* MacBookPro12,1 */ {},
+  /* MacBookPro13,1 */ {},
+  /* MacBookPro13,2 */ {},
+  /* MacBookPro13,3 */ {},
+  /* MacBookPro14,1 */ {},
+  /* MacBookPro14,2 */ {},
+  /* MacBookPro14,3 */ {},
+  /* MacBookPro15,1 */ {},
+  /* MacBookPro15,2 */ {},
+  /* MacBookPro15,3 */ {},
+  /* MacBookPro15,4 */ {},
+  /* MacBookPro16,1 */ {},
+  /* MacBookPro16,2 */ {},
+  /* MacBookPro16,3 */ {},
+  /* MacBookPro16,4 */ {},
+  /* MacBookPro2,1  */ {},
+  /* MacBookPro2,2  */ {},
+  /* MacBookPro3,1  */ {},
+  /* MacBookPro4,1  */ {},
+  /* MacBookPro5,1  */ {},
+  /* MacBookPro5,2  */ {},
+  /* MacBookPro5,3  */ {},
+  /* MacBookPro5,4  */ {},
+  /* MacBookPro5,5  */ {},
+  /* MacBookPro6,1  */ {},
+  /* MacBookPro6,2  */ {},
+  /* MacBookPro7,1  */ {},
+  /* MacBookPro8,1  */ {},
+  /* MacBookPro8,2  */ {},
+  /* MacBookPro8,3  */ {},
+  /* MacBookPro9,1  */ {},
+  /* MacBookPro9,2  */ {},
+  /* MacPro1,1      */ {},
+  /* MacPro2,1      */ {},
+  /* MacPro3,1      */ {},
+  /* MacPro4,1      */ {},
+  /* MacPro5,1      */ {},
+  /* MacPro6,1      */ {},
+  /* MacPro7,1      */ {},
+  /* Macmini1,1     */ {},
+  /* Macmini2,1     */ {},
+  /* Macmini3,1     */ {},
+  /* Macmini4,1     */ {},
+  /* Macmini5,1     */ {},
+  /* Macmini5,2     */ {},
+  /* Macmini5,3     */ {},
+  /* Macmini6,1     */ {},
+  /* Macmini6,2     */ {},
+  /* Macmini7,1     */ {},
+  /* Macmini8,1     */ {},
+  /* Xserve1,1      */ {},
+  /* Xserve2,1      */ {},
+  /* Xserve3,1      */ {},
+  /* iMac10,1       */ {},
+  /* iMac11,1       */ {},
+  /* iMac11,2       */ {},
+  /* iMac11,3       */ {},
+  /* iMac12,1       */ {},
+  /* iMac12,2       */ {},
+  /* iMac13,1       */ {},
+  /* iMac13,2       */ {},
+  /* iMac13,3       */ {},
+  /* iMac14,1       */ {},
+  /* iMac14,2       */ {},
+  /* iMac14,3       */ {},
+  /* iMac14,4       */ {},
+  /* iMac15,1       */ {},
+  /* iMac16,1       */ {},
+  /* iMac16,2       */ {},
+  /* iMac17,1       */ {},
+  /* iMac18,1       */ {},
+  /* iMac18,2       */ {},
+  /* iMac18,3       */ {},
+  /* iMac19,1       */ {},
+  /* iMac19,2       */ {},
+  /* iMac20,1       */ {},
+  /* iMac20,2       */ {},
+  /* iMac4,1        */ {},
+  /* iMac4,2        */ {},
+  /* iMac5,1        */ {},
+  /* iMac5,2        */ {},
+  /* iMac6,1        */ {},
+  /* iMac7,1        */ {},
+  /* iMac8,1        */ {},
+  /* iMac9,1        */ {},
+  /* iMacPro1,1     */ {},
+}
+
+var AppleModelCPU = []string{
+  /* MacBook1,1     */ "Intel Core Duo T2400 @ 1.83 GHz",
+  /* MacBook10,1    */ "Intel Core m3-7Y32 @ 1.10 GHz",
+  /* MacBook2,1     */ "Intel Core 2 Duo T5600 @ 1.83 GHz",
+  /* MacBook3,1     */ "Intel Core 2 Duo T7500 @ 2.20 GHz",
+  /* MacBook4,1     */ "Intel Core 2 Duo T8300 @ 2.40 GHz",
+  /* MacBook5,1     */ "Intel Core 2 Duo P8600 @ 2.40 GHz",
+  /* MacBook5,2     */ "Intel Core 2 Duo P7450 @ 2.13 GHz",
+  /* MacBook6,1     */ "Intel Core 2 Duo P7550 @ 2.26 GHz",
+  /* MacBook7,1     */ "Intel Core 2 Duo P8600 @ 2.40 GHz",
+  /* MacBook8,1     */ "Intel Core M 5Y51 @ 1.10 GHz",
+  /* MacBook9,1     */ "Intel Core m3-6Y30 @ 1.10 GHz",
+  /* MacBookAir1,1  */ "Intel Core 2 Duo P7500 @ 1.60 GHz",
+  /* MacBookAir2,1  */ "Intel Core 2 Duo SL9600 @ 2.13 GHz",
+  /* MacBookAir3,1  */ "Intel Core 2 Duo SU9400 @ 1.40 GHz",
+  /* MacBookAir3,2  */ "Intel Core 2 Duo SL9400 @ 1.86 GHz",
+  /* MacBookAir4,1  */ "Intel Core i5-2467M @ 1.60 GHz",
+  /* MacBookAir4,2  */ "Intel Core i5-2557M @ 1.70 GHz",
+  /* MacBookAir5,1  */ "Intel Core i5-3317U @ 1.70 GHz",
+  /* MacBookAir5,2  */ "Intel Core i5-3317U @ 1.70GHz",
+  /* MacBookAir6,1  */ "Intel Core i5-4250U @ 1.30 GHz",
+  /* MacBookAir6,2  */ "Intel Core i5-4250U @ 1.30 GHz",
+  /* MacBookAir7,1  */ "Intel Core i5-5250U @ 1.60 GHz",
+  /* MacBookAir7,2  */ "Intel Core i5-5250U @ 1.60 GHz",
+  /* MacBookAir8,1  */ "Intel Core i5-8210Y @ 1.60 GHz",
+  /* MacBookAir8,2  */ "Intel Core i5-8210Y @ 1.60 GHz",
+  /* MacBookAir9,1  */ "Intel Core i3-1000NG4 @ 1.10 GHz",
+  /* MacBookPro1,1  */ "Intel Core Duo L2400 @ 1.66 GHz",
+  /* MacBookPro1,2  */ "Intel Core Duo T2600 @ 2.16 GHz",
+  /* MacBookPro10,1 */ "Intel Core i7-3615QM @ 2.30 GHz",
+  /* MacBookPro10,2 */ "Intel Core i5-3210M @ 2.50 GHz",
+  /* MacBookPro11,1 */ "Intel Core i5-4258U @ 2.40 GHz",
+  /* MacBookPro11,2 */ "Intel Core i7-4770HQ @ 2.20 GHz",
+  /* MacBookPro11,3 */ "Intel Core i7-4850HQ @ 2.30 GHz",
+  /* MacBookPro11,4 */ "Intel Core i7-4770HQ @ 2.20 GHz",
+  /* MacBookPro11,5 */ "Intel Core i7-4870HQ @ 2.50 GHz",
+  /* MacBookPro12,1 */ "Intel Core i5-5257U @ 2.70 GHz",
+  /* MacBookPro13,1 */ "Intel Core i5-6360U @ 2.00 GHz",
+  /* MacBookPro13,2 */ "Intel Core i7-6567U @ 3.30 GHz",
+  /* MacBookPro13,3 */ "Intel Core i7-6700HQ @ 2.60 GHz",
+  /* MacBookPro14,1 */ "Intel Core i5-7360U @ 2.30 GHz",
+  /* MacBookPro14,2 */ "Intel Core i5-7267U @ 3.10 GHz",
+  /* MacBookPro14,3 */ "Intel Core i7-7700HQ @ 2.80 GHz",
+  /* MacBookPro15,1 */ "Intel Core i7-8750H @ 2.20 GHz",
+  /* MacBookPro15,2 */ "Intel Core i7-8559U @ 2.70 GHz",
+  /* MacBookPro15,3 */ "Intel Core i7-8850H @ 2.60 GHz",
+  /* MacBookPro15,4 */ "Intel Core i5-8257U @ 1.40 GHz",
+  /* MacBookPro16,1 */ "Intel Core i7-9750H @ 2.60 GHz",
+  /* MacBookPro16,2 */ "Intel Core i5-1038NG7 @ 2.00 GHz",
+  /* MacBookPro16,3 */ "Intel Core i5-8257U @ 1.40 GHz",
+  /* MacBookPro16,4 */ "Intel Core i7-9750H @ 2.60 GHz",
+  /* MacBookPro2,1  */ "Intel Core 2 Duo T7600 @ 2.33 GHz",
+  /* MacBookPro2,2  */ "Intel Core 2 Duo T7400 @ 2.16 GHz",
+  /* MacBookPro3,1  */ "Intel Core 2 Duo T7700 @ 2.40 GHz",
+  /* MacBookPro4,1  */ "Intel Core 2 Duo T8300 @ 2.40 GHz",
+  /* MacBookPro5,1  */ "Intel Core 2 Duo P8600 @ 2.40 GHz",
+  /* MacBookPro5,2  */ "Intel Core 2 Duo T9600 @ 2.80 GHz",
+  /* MacBookPro5,3  */ "Intel Core 2 Duo P8800 @ 2.66 GHz",
+  /* MacBookPro5,4  */ "Intel Core 2 Duo P8700 @ 2.53 GHz",
+  /* MacBookPro5,5  */ "Intel Core 2 Duo P7550 @ 2.26 GHz",
+  /* MacBookPro6,1  */ "Intel Core i5-540M @ 2.53 GHz",
+  /* MacBookPro6,2  */ "Intel Core i5-520M @ 2.40 GHz",
+  /* MacBookPro7,1  */ "Intel Core 2 Duo P8600 @ 2.40 GHz",
+  /* MacBookPro8,1  */ "Intel Core i5-2415M @ 2.30 GHz",
+  /* MacBookPro8,2  */ "Intel Core i7-2675QM @ 2.20 GHz",
+  /* MacBookPro8,3  */ "Intel Core i7-2820QM @ 2.30 GHz",
+  /* MacBookPro9,1  */ "Intel Core i7-3615QM @ 2.30 GHz",
+  /* MacBookPro9,2  */ "Intel Core i5-3210M @ 2.50 GHz",
+  /* MacPro1,1      */ "Intel Core Xeon 5130 x2 @ 2.00 GHz",
+  /* MacPro2,1      */ "Intel Xeon X5365 x2 @ 3.00 GHz",
+  /* MacPro3,1      */ "Intel Xeon E5462 x2 @ 2.80 GHz",
+  /* MacPro4,1      */ "Intel Xeon W3520 @ 2.66 GHz",
+  /* MacPro5,1      */ "Intel Xeon W3530 @ 2.80 GHz",
+  /* MacPro6,1      */ "Intel Xeon E5-1620 v2 @ 3.70 GHz",
+  /* MacPro7,1      */ "Intel Xeon W-3245M CPU @ 3.20 GHz",
+  /* Macmini1,1     */ "Intel Core Solo T1200 @ 1.50 GHz",
+  /* Macmini2,1     */ "Intel Core 2 Duo T5600 @ 1.83 GHz",
+  /* Macmini3,1     */ "Intel Core 2 Duo P7350 @ 2.00 GHz",
+  /* Macmini4,1     */ "Intel Core 2 Duo P8600 @ 2.40 GHz",
+  /* Macmini5,1     */ "Intel Core i5-2415M @ 2.30 GHz",
+  /* Macmini5,2     */ "Intel Core i5-2520M @ 2.50 GHz",
+  /* Macmini5,3     */ "Intel Core i7-2635QM @ 2.00 GHz",
+  /* Macmini6,1     */ "Intel Core i5-3210M @ 2.50 GHz",
+  /* Macmini6,2     */ "Intel Core i7-3615QM @ 2.30 GHz",
+  /* Macmini7,1     */ "Intel Core i5-4260U @ 1.40 GHz",
+  /* Macmini8,1     */ "Intel Core i7-8700B @ 3.20 GHz",
+  /* Xserve1,1      */ "Intel Xeon 5130 x2 @ 2.00 GHz",
+  /* Xserve2,1      */ "Intel Xeon E5462 x2 @ 2.80 GHz",
+  /* Xserve3,1      */ "Intel Xeon E5520 x2 @ 2.26 GHz",
+  /* iMac10,1       */ "Intel Core 2 Duo E7600 @ 3.06 GHz",
+  /* iMac11,1       */ "Intel Core i5-750 @ 2.66 GHz",
+  /* iMac11,2       */ "Intel Core i3-540 @ 3.06 GHz",
+  /* iMac11,3       */ "Intel Core i5-760 @ 2.80 GHz",
+  /* iMac12,1       */ "Intel Core i5-2400S @ 2.50 GHz",
+  /* iMac12,2       */ "Intel Core i7-2600 @ 3.40 GHz",
+  /* iMac13,1       */ "Intel Core i7-3770S @ 3.10 GHz",
+  /* iMac13,2       */ "Intel Core i5-3470S @ 2.90 GHz",
+  /* iMac13,3       */ "Intel Core i5-3470S @ 2.90 GHz",
+  /* iMac14,1       */ "Intel Core i5-4570R @ 2.70 GHz",
+  /* iMac14,2       */ "Intel Core i7-4771 @ 3.50 GHz",
+  /* iMac14,3       */ "Intel Core i5-4570S @ 2.90 GHz",
+  /* iMac14,4       */ "Intel Core i5-4260U @ 1.40 GHz",
+  /* iMac15,1       */ "Intel Core i7-4790k @ 4.00 GHz",
+  /* iMac16,1       */ "Intel Core i5-5250U @ 1.60 GHz",
+  /* iMac16,2       */ "Intel Core i5-5675R @ 3.10 GHz",
+  /* iMac17,1       */ "Intel Core i5-6500 @ 3.20 GHz",
+  /* iMac18,1       */ "Intel Core i5-7360U @ 2.30 GHz",
+  /* iMac18,2       */ "Intel Core i5-7400 @ 3.00 GHz",
+  /* iMac18,3       */ "Intel Core i5-7600K @ 3.80 GHz",
+  /* iMac19,1       */ "Intel Core i9-9900K @ 3.60 GHz",
+  /* iMac19,2       */ "Intel Core i5-8500 @ 3.00 GHz",
+  /* iMac20,1       */ "Intel Core i5-10500 @ 3.10 GHz",
+  /* iMac20,2       */ "Intel Core i9-10910 @ 3.60 GHz",
+  /* iMac4,1        */ "Intel Core Duo T2400 @ 1.83 GHz",
+  /* iMac4,2        */ "Intel Core Duo T2400 @ 1.83 GHz",
+  /* iMac5,1        */ "Intel Core 2 Duo T7200 @ 2.00 GHz",
+  /* iMac5,2        */ "Intel Core 2 Duo T5600 @ 1.83 GHz",
+  /* iMac6,1        */ "Intel Core 2 Duo T7400 @ 2.16 GHz",
+  /* iMac7,1        */ "Intel Core 2 Duo T7300 @ 2.00 GHz",
+  /* iMac8,1        */ "Intel Core 2 Duo E8435 @ 3.06 GHz",
+  /* iMac9,1        */ "Intel Core 2 Duo E8135 @ 2.66 GHz",
+  /* iMacPro1,1     */ "Intel Xeon W-2140B CPU @ 3.20 GHz",
+}
+
 var AppleModelDesc = []AppleModelDescription{
  {"00W", "Xserve (Late 2006)"},
  {"01P", "MacBook (13-inch Late 2007)"},
@@ -0,0 +1,141 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// where Linux exposes the network interfaces, can be changed for testing
+var sysNetRoot = "/sys/class/net"
+
+type NIC struct {
+	Name    string
+	MAC     []byte
+	Virtual bool // no backing device (loopback, bridges, tunnels, veth, ...)
+}
+
+// Local checks the locally administered bit, these addresses are not assigned by a vendor
+func (n *NIC) Local() bool {
+	return n.MAC[0]&0x02 != 0
+}
+
+// Multicast checks the multicast bit, these are never valid interface addresses
+func (n *NIC) Multicast() bool {
+	return n.MAC[0]&0x01 != 0
+}
+
+// Usable checks if the interface MAC address can be used as ROM
+func (n *NIC) Usable() bool {
+	zero := true
+	for _, b := range n.MAC {
+		if b != 0 {
+			zero = false
+		}
+	}
+	return !n.Virtual && !n.Local() && !n.Multicast() && !zero
+}
+
+// ROM returns the MAC address in the 12 hex digits ROM format
+func (n *NIC) ROM() string {
+	return strings.ToUpper(hex.EncodeToString(n.MAC))
+}
+
+// ROMData returns the MAC address as base64 like plist data fields
+func (n *NIC) ROMData() string {
+	return base64.StdEncoding.EncodeToString(n.MAC)
+}
+
+// listNICs reads all the ethernet network interfaces from a sysfs class/net directory
+func listNICs(root string) ([]NIC, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read network interfaces (only supported on Linux): %s", err)
+	}
+	var nics []NIC
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(root, e.Name(), "address"))
+		if err != nil {
+			continue
+		}
+		addr := strings.ReplaceAll(strings.TrimSpace(string(data)), ":", "")
+		mac, err := hex.DecodeString(addr)
+		// infiniband and others have longer addresses
+		if err != nil || len(mac) != 6 {
+			continue
+		}
+		nic := NIC{Name: e.Name(), MAC: mac}
+		// physical interfaces have a link to the backing device
+		if _, err := os.Stat(filepath.Join(root, e.Name(), "device")); err != nil {
+			nic.Virtual = true
+		}
+		nics = append(nics, nic)
+	}
+	sort.Slice(nics, func(i, j int) bool { return nics[i].Name < nics[j].Name })
+	return nics, nil
+}
+
+// findNIC returns the named interface or the first usable one if name is empty
+func findNIC(root string, name string) (NIC, error) {
+	nics, err := listNICs(root)
+	if err != nil {
+		return NIC{}, err
+	}
+	for _, nic := range nics {
+		if name == "" && nic.Usable() {
+			return nic, nil
+		}
+		if name != "" && nic.Name == name {
+			switch {
+			case nic.Virtual:
+				return NIC{}, fmt.Errorf("Interface %s is virtual", name)
+			case nic.Local():
+				return NIC{}, fmt.Errorf("Interface %s has a locally administered address", name)
+			case !nic.Usable():
+				return NIC{}, fmt.Errorf("Interface %s has an invalid address", name)
+			}
+			return nic, nil
+		}
+	}
+	if name != "" {
+		return NIC{}, fmt.Errorf("Interface %s not found", name)
+	}
+	return NIC{}, fmt.Errorf("No physical network interface with a vendor assigned address found")
+}
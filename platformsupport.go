@@ -0,0 +1,205 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// the lists of supported Macs in the PlatformSupport.plist of macOS installers
+// older Macs are matched by board-id and newer ones by product name
+type PlatformSupport struct {
+	BoardIDs []string
+	Models   []string
+}
+
+type SupportCheck struct {
+	Model          string   `json:"model,omitempty"`
+	BoardIDs       []string `json:"board_ids,omitempty"`
+	BoardIDMatch   string   `json:"board_id_match,omitempty"`
+	ModelSupported bool     `json:"model_supported"`
+	Supported      bool     `json:"supported"`
+	// the installer lists board-ids but the model has none to check
+	BoardIDUnknown bool `json:"board_id_unknown,omitempty"`
+	// all the models of the database supported by the installer
+	SupportedModels []string `json:"supported_models"`
+	// the models not supported by name that have no board-ids to check
+	UnknownModels []string `json:"unknown_models,omitempty"`
+}
+
+// plistArrays returns the string arrays of the top level dictionary of a XML plist
+func plistArrays(data []byte) (map[string][]string, error) {
+	if bytes.HasPrefix(data, []byte("bplist")) {
+		return nil, fmt.Errorf("Binary plists are not supported, convert it with plutil -convert xml1")
+	}
+	arrays := make(map[string][]string)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	// element path from the plist root, the top level keys are inside plist/dict
+	var path []string
+	key, text := "", ""
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid plist: %s", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			text = ""
+		case xml.CharData:
+			text += string(t)
+		case xml.EndElement:
+			inTopDict := len(path) >= 3 && path[0] == "plist" && path[1] == "dict"
+			switch {
+			case inTopDict && len(path) == 3 && t.Name.Local == "key":
+				key = strings.TrimSpace(text)
+			case inTopDict && len(path) == 4 && path[2] == "array" && t.Name.Local == "string":
+				arrays[key] = append(arrays[key], strings.TrimSpace(text))
+			}
+			path = path[:len(path)-1]
+		}
+	}
+	if len(arrays) == 0 && key == "" {
+		return nil, fmt.Errorf("Invalid plist: no top level dictionary")
+	}
+	return arrays, nil
+}
+
+// readPlatformSupport loads the supported board-ids and models of a PlatformSupport.plist
+func readPlatformSupport(path string) (*PlatformSupport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	arrays, err := plistArrays(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	ps := &PlatformSupport{BoardIDs: arrays["SupportedBoardIds"], Models: arrays["SupportedModelProperties"]}
+	if len(ps.BoardIDs) == 0 && len(ps.Models) == 0 {
+		return nil, fmt.Errorf("%s: no SupportedBoardIds or SupportedModelProperties", path)
+	}
+	return ps, nil
+}
+
+func containsFold(list []string, value string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// boardIDMatch returns the first board-id of the model supported by the installer
+func (ps *PlatformSupport) boardIDMatch(model AppleModel) string {
+	for _, id := range modelSMBIOS(model).BoardProduct {
+		if containsFold(ps.BoardIDs, id) {
+			return id
+		}
+	}
+	return ""
+}
+
+// Supports checks if the installer supports the model by board-id or product name
+func (ps *PlatformSupport) Supports(model AppleModel) bool {
+	return ps.boardIDMatch(model) != "" || containsFold(ps.Models, ApplePlatformData[model].productName)
+}
+
+// boardIDUnknown checks if the model can't be checked against the board-ids of the installer
+func (ps *PlatformSupport) boardIDUnknown(model AppleModel) bool {
+	return len(ps.BoardIDs) > 0 && len(modelSMBIOS(model).BoardProduct) == 0
+}
+
+// checkSupport checks a model against the installer, a negative model only lists the supported models
+func checkSupport(ps *PlatformSupport, model int) SupportCheck {
+	c := SupportCheck{SupportedModels: []string{}}
+	if model >= 0 {
+		c.Model = ApplePlatformData[model].productName
+		c.BoardIDs = modelSMBIOS(AppleModel(model)).BoardProduct
+		c.BoardIDMatch = ps.boardIDMatch(AppleModel(model))
+		c.ModelSupported = containsFold(ps.Models, c.Model)
+		c.Supported = c.BoardIDMatch != "" || c.ModelSupported
+		c.BoardIDUnknown = !c.Supported && ps.boardIDUnknown(AppleModel(model))
+	}
+	for i := range ApplePlatformData {
+		if ps.Supports(AppleModel(i)) {
+			c.SupportedModels = append(c.SupportedModels, ApplePlatformData[i].productName)
+		} else if ps.boardIDUnknown(AppleModel(i)) {
+			c.UnknownModels = append(c.UnknownModels, ApplePlatformData[i].productName)
+		}
+	}
+	return c
+}
+
+func (c *SupportCheck) Print() {
+	supported := func(b bool) string {
+		if b {
+			return "supported"
+		}
+		return "not supported"
+	}
+	if c.Model != "" {
+		fmt.Printf("%14s: %s\n", "Model", c.Model)
+		switch {
+		case len(c.BoardIDs) == 0:
+			fmt.Printf("%14s: unknown, load it with --db\n", "Board-id")
+		case c.BoardIDMatch != "":
+			fmt.Printf("%14s: %s (supported)\n", "Board-id", c.BoardIDMatch)
+		default:
+			fmt.Printf("%14s: %s (not supported)\n", "Board-id", strings.Join(c.BoardIDs, ", "))
+		}
+		fmt.Printf("%14s: %s\n", "Product name", supported(c.ModelSupported))
+		if c.BoardIDUnknown {
+			fmt.Printf("%14s: board-id unknown\n\n", "Result")
+		} else {
+			fmt.Printf("%14s: %s\n\n", "Result", supported(c.Supported))
+		}
+	}
+	fmt.Printf("Supported models:\n")
+	for _, m := range c.SupportedModels {
+		fmt.Printf(" - %s\n", m)
+	}
+	if len(c.UnknownModels) > 0 {
+		fmt.Printf("%d models have no board-id to check, load them with --db\n", len(c.UnknownModels))
+	}
+}
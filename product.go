@@ -0,0 +1,112 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// a product code with its marketing description and the models that own it
+type ProductInfo struct {
+	Code   string   `json:"code"`
+	Name   string   `json:"name,omitempty"`
+	Models []string `json:"models"`
+	// only codes owned by a model can be used for generation
+	Usable bool `json:"usable"`
+}
+
+// productOwners returns the models with the product code in AppleModelCode
+func productOwners(code string) []string {
+	owners := []string{}
+	for _, i := range modelCodeIndex[code] {
+		owners = append(owners, ApplePlatformData[i].productName)
+	}
+	return owners
+}
+
+// lookupProduct returns the description and owners of a product code
+func lookupProduct(code string) (ProductInfo, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != MODEL_CODE_OLD_LEN && len(code) != MODEL_CODE_NEW_LEN {
+		return ProductInfo{}, fmt.Errorf("Product code %s is neither %d nor %d symbols long", code, MODEL_CODE_OLD_LEN, MODEL_CODE_NEW_LEN)
+	}
+	p := ProductInfo{Code: code, Name: productDescIndex[code], Models: productOwners(code)}
+	p.Usable = len(p.Models) > 0
+	if p.Name == "" && !p.Usable {
+		return ProductInfo{}, fmt.Errorf("Unknown product code %s", code)
+	}
+	return p, nil
+}
+
+// searchProducts returns the product codes whose description contains the text, ignoring case
+func searchProducts(text string) []ProductInfo {
+	text = strings.ToLower(strings.TrimSpace(text))
+	products := []ProductInfo{}
+	seen := make(map[string]bool)
+	for _, d := range AppleModelDesc {
+		if seen[d.code] || !strings.Contains(strings.ToLower(d.name), text) {
+			continue
+		}
+		seen[d.code] = true
+		p := ProductInfo{Code: d.code, Name: d.name, Models: productOwners(d.code)}
+		p.Usable = len(p.Models) > 0
+		products = append(products, p)
+	}
+	return products
+}
+
+func (p *ProductInfo) Print() {
+	fmt.Printf("%14s: %s\n", "Code", p.Code)
+	if p.Name != "" {
+		fmt.Printf("%14s: %s\n", "Description", p.Name)
+	}
+	if !p.Usable {
+		fmt.Printf("%14s: none, the code can't be used for generation\n", "Models")
+		return
+	}
+	fmt.Printf("%14s: %s\n", "Models", strings.Join(p.Models, ", "))
+	for _, m := range p.Models {
+		fmt.Printf("%14s: --model %s --model-code %s\n", "Generate", m, p.Code)
+	}
+}
+
+// PrintLine prints the product in the --list-products format with its owners
+func (p *ProductInfo) PrintLine() {
+	owners := "not usable"
+	if p.Usable {
+		owners = strings.Join(p.Models, ", ")
+	}
+	fmt.Printf("%4s - %s [%s]\n", p.Code, p.Name, owners)
+}
@@ -0,0 +1,87 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// names of the CODE_PICK_* modes as used in the command line
+var CodePickNames = []string{"first", "uniform", "weighted"}
+
+// a profile is a set of generation defaults that can be overridden by the other options
+type Profile struct {
+	name        string
+	codePick    int
+	countryPick int
+}
+
+var Profiles = []Profile{
+	// original macserial behavior, same model and board codes for every serial
+	{name: "stable", codePick: CODE_PICK_FIRST, countryPick: COUNTRY_PICK_BASE},
+	// spread serials over all the codes and factories of the model like a real fleet
+	{name: "realistic", codePick: CODE_PICK_WEIGHTED, countryPick: COUNTRY_PICK_PLAUSIBLE},
+}
+
+func findProfile(name string) (Profile, error) {
+	for _, p := range Profiles {
+		if p.name == name {
+			return p, nil
+		}
+	}
+	var names []string
+	for _, p := range Profiles {
+		names = append(names, p.name)
+	}
+	return Profile{}, fmt.Errorf("Unknown profile %s, available profiles: %s", name, strings.Join(names, ", "))
+}
+
+func findCodePick(name string) (int, error) {
+	for i, n := range CodePickNames {
+		if n == name {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("Unknown code selection %s, available: %s", name, strings.Join(CodePickNames, ", "))
+}
+
+func findCountryPick(name string) (int, error) {
+	for i, n := range CountryPickNames {
+		if n == name {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("Unknown country selection %s, available: %s", name, strings.Join(CountryPickNames, ", "))
+}
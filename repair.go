@@ -0,0 +1,171 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// maximum number of characters replaced when repairing a serial
+// each extra substitution multiplies the number of candidates so keep it small
+const REPAIR_MAX_SUBST = 2
+
+// groups of characters that are easily mistaken for each other
+// I and O are never valid in a serial so normalizeSerial already takes care of them
+var serialConfusables = []string{
+	"0DQ",
+	"1L7T",
+	"2Z",
+	"5S",
+	"6G",
+	"8B",
+	"UV",
+}
+
+type RepairCandidate struct {
+	Serial  string
+	Subst   int // number of replaced characters
+	Score   int
+	Decoded Serial
+}
+
+// normalizeSerial fixes the usual typos found in user supplied serials
+// lowercase letters, spaces and dashes, and O and I typed instead of 0 and 1
+func normalizeSerial(serial string) string {
+	var b strings.Builder
+	for _, c := range strings.ToUpper(serial) {
+		switch c {
+		case ' ', '\t', '-', '_', '.':
+			continue
+		case 'O':
+			b.WriteByte('0')
+		case 'I':
+			b.WriteByte('1')
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// confusablesFor returns all the characters that can be mistaken for c
+func confusablesFor(c byte) string {
+	var ret []byte
+	for _, group := range serialConfusables {
+		if strings.IndexByte(group, c) < 0 {
+			continue
+		}
+		for i := 0; i < len(group); i++ {
+			if group[i] != c && strings.IndexByte(string(ret), group[i]) < 0 {
+				ret = append(ret, group[i])
+			}
+		}
+	}
+	return string(ret)
+}
+
+// scoreSerial ranks a decoded serial by how plausible it is
+// a known model code weights more than anything else because it is the longest field
+func scoreSerial(s Serial) int {
+	score := 0
+	if s.index >= 0 {
+		score += 4
+	}
+	if s.countryIndex >= 0 {
+		score += 2
+	}
+	if s.DecodedYear > 0 && s.index >= 0 && modelHasYear(AppleModel(s.index), s.DecodedYear) {
+		score += 2
+	}
+	if s.DecodedWeek > 0 {
+		score++
+	}
+	if s.Valid {
+		score++
+	}
+	return score
+}
+
+// repairSerial proposes candidate serials by replacing confusable characters
+// candidates are sorted by score and then by the number of replaced characters
+// only candidates that decode to a known model are returned
+func repairSerial(serial string) []RepairCandidate {
+	serial = normalizeSerial(serial)
+	if len(serial) != SERIAL_OLD_LEN && len(serial) != SERIAL_NEW_LEN {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var candidates []RepairCandidate
+
+	var walk func(buf []byte, pos int, subst int)
+	walk = func(buf []byte, pos int, subst int) {
+		if pos == len(buf) {
+			str := string(buf)
+			if seen[str] {
+				return
+			}
+			seen[str] = true
+			s, err := decodeSerial(str, false)
+			if err != nil || s.index < 0 {
+				return
+			}
+			candidates = append(candidates, RepairCandidate{Serial: str, Subst: subst, Score: scoreSerial(s), Decoded: s})
+			return
+		}
+		walk(buf, pos+1, subst)
+		if subst == REPAIR_MAX_SUBST {
+			return
+		}
+		orig := buf[pos]
+		for _, c := range []byte(confusablesFor(orig)) {
+			buf[pos] = c
+			walk(buf, pos+1, subst+1)
+		}
+		buf[pos] = orig
+	}
+	walk([]byte(serial), 0, 0)
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		if candidates[i].Subst != candidates[j].Subst {
+			return candidates[i].Subst < candidates[j].Subst
+		}
+		return candidates[i].Serial < candidates[j].Serial
+	})
+	return candidates
+}
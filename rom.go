@@ -0,0 +1,141 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+// AppleRomPrefix is generated from a local copy of the Wireshark manuf file
+//go:generate go run ./tools/genromprefix -manuf manuf -out romprefix_autogen.go
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// number of bytes in a ROM value (a MAC address)
+const ROM_LEN = 6
+
+type ROMInfo struct {
+	ROM       string   `json:"rom"`
+	Data      string   `json:"data"` // base64 as used in plist data fields
+	OUI       string   `json:"oui"`
+	Apple     bool     `json:"apple"`
+	Multicast bool     `json:"multicast"`
+	Local     bool     `json:"locally_administered"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// parseROM decodes a ROM in hex, with or without separators, or base64 plist data
+func parseROM(str string) ([]byte, error) {
+	str = strings.TrimSpace(str)
+	clean := strings.NewReplacer(":", "", "-", "", ".", "", " ", "").Replace(str)
+	// 12 characters would also be valid base64 so try hex first
+	if len(clean) == ROM_LEN*2 {
+		if b, err := hex.DecodeString(clean); err == nil {
+			return b, nil
+		}
+	}
+	b, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid ROM %s, use hex or base64", str)
+	}
+	if len(b) != ROM_LEN {
+		return nil, fmt.Errorf("Invalid ROM length %d bytes, should be %d", len(b), ROM_LEN)
+	}
+	return b, nil
+}
+
+// isAppleROMPrefix checks if the 6 hex digits prefix is in AppleRomPrefix
+func isAppleROMPrefix(prefix string) bool {
+	for _, p := range AppleRomPrefix {
+		if p == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// romInfo parses and inspects a ROM value
+func romInfo(str string) (ROMInfo, error) {
+	b, err := parseROM(str)
+	if err != nil {
+		return ROMInfo{}, err
+	}
+	info := ROMInfo{
+		ROM:       strings.ToUpper(hex.EncodeToString(b)),
+		Data:      base64.StdEncoding.EncodeToString(b),
+		Multicast: b[0]&0x01 != 0,
+		Local:     b[0]&0x02 != 0,
+	}
+	info.OUI = info.ROM[:6]
+	info.Apple = isAppleROMPrefix(info.OUI)
+
+	switch info.ROM {
+	case "000000000000":
+		info.Warnings = append(info.Warnings, "ROM is all zeros")
+	case "FFFFFFFFFFFF":
+		info.Warnings = append(info.Warnings, "ROM is the broadcast address")
+	default:
+		if info.Multicast {
+			info.Warnings = append(info.Warnings, "ROM is a multicast address")
+		}
+	}
+	if info.Local {
+		info.Warnings = append(info.Warnings, "ROM is locally administered")
+	}
+	if !info.Apple {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("OUI %s is not an Apple prefix", info.OUI))
+	}
+	return info, nil
+}
+
+func (r *ROMInfo) Print() {
+	cast := "Unicast"
+	if r.Multicast {
+		cast = "Multicast"
+	}
+	admin := "Universal"
+	if r.Local {
+		admin = "Local"
+	}
+	fmt.Printf("%14s: %s\n", "ROM", r.ROM)
+	fmt.Printf("%14s: %s\n", "Data", r.Data)
+	fmt.Printf("%14s: %s\n", "OUI", r.OUI)
+	fmt.Printf("%14s: %s\n", "Apple OUI", yesNo(r.Apple))
+	fmt.Printf("%14s: %s\n", "Type", cast)
+	fmt.Printf("%14s: %s\n", "Admin", admin)
+	for _, w := range r.Warnings {
+		fmt.Printf("WARN: %s\n", w)
+	}
+}
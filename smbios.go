@@ -0,0 +1,206 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SMBIOS and DataHub values of a model from the AppleModels database
+// the generated AppleSMBIOSInfo table is parallel to ApplePlatformData, empty fields are unknown
+type SMBIOSInfo struct {
+	BoardProduct         []string `json:"board_product,omitempty" yaml:"board_product"` // board-id, some models have more than one
+	BIOSVersion          string   `json:"bios_version,omitempty" yaml:"bios_version"`
+	BIOSReleaseDate      string   `json:"bios_release_date,omitempty" yaml:"bios_release_date"`
+	ChassisType          int      `json:"chassis_type,omitempty" yaml:"chassis_type"`
+	ChassisAssetTag      string   `json:"chassis_asset_tag,omitempty" yaml:"chassis_asset_tag"`
+	FirmwareFeatures     uint64   `json:"firmware_features,omitempty" yaml:"firmware_features"`
+	FirmwareFeaturesMask uint64   `json:"firmware_features_mask,omitempty" yaml:"firmware_features_mask"`
+	SmcRevision          []int    `json:"smc_revision,omitempty" yaml:"smc_revision"` // raw bytes as in the database
+	SmcBranch            []int    `json:"smc_branch,omitempty" yaml:"smc_branch"`
+	SmcPlatform          []int    `json:"smc_platform,omitempty" yaml:"smc_platform"`
+}
+
+// the --keygen output in JSON format
+type Keygen struct {
+	Type        string      `json:"type"`
+	Serial      string      `json:"serial"`
+	BoardSerial string      `json:"board_serial"`
+	UUID        string      `json:"uuid"`
+	ROM         string      `json:"rom"`
+	ROMData     string      `json:"rom_data,omitempty"` // base64 plist data when the ROM comes from a NIC
+	SMBIOS      *SMBIOSInfo `json:"smbios,omitempty"`
+	Warnings    []string    `json:"warnings,omitempty"`
+}
+
+// modelSMBIOS returns the SMBIOS information of the model
+func modelSMBIOS(model AppleModel) SMBIOSInfo {
+	if int(model) >= len(AppleSMBIOSInfo) {
+		return SMBIOSInfo{}
+	}
+	return AppleSMBIOSInfo[model]
+}
+
+// BoardID returns the main board-id of the model
+func (m *SMBIOSInfo) BoardID() string {
+	if len(m.BoardProduct) == 0 {
+		return ""
+	}
+	return m.BoardProduct[0]
+}
+
+// Empty checks if no SMBIOS information is known
+func (m *SMBIOSInfo) Empty() bool {
+	return len(m.BoardProduct) == 0 && m.BIOSVersion == "" && m.BIOSReleaseDate == "" && m.ChassisType == 0 &&
+		m.ChassisAssetTag == "" && m.FirmwareFeatures == 0 && m.FirmwareFeaturesMask == 0 &&
+		len(m.SmcRevision) == 0 && len(m.SmcBranch) == 0 && len(m.SmcPlatform) == 0
+}
+
+// smcBytesString formats SMC bytes as text if they are a NUL padded ASCII string or as hex data otherwise
+func smcBytesString(data []int) string {
+	end := len(data)
+	for end > 0 && data[end-1] == 0 {
+		end--
+	}
+	text := end > 0
+	for _, v := range data[:end] {
+		if v < 0x20 || v > 0x7E {
+			text = false
+		}
+	}
+	var b strings.Builder
+	for i, v := range data {
+		if text && i < end {
+			b.WriteByte(byte(v))
+		} else if !text {
+			fmt.Fprintf(&b, "%02X", v)
+		}
+	}
+	return b.String()
+}
+
+// allFields returns the label and value of each field, empty if unknown
+func (m *SMBIOSInfo) allFields() [][2]string {
+	hex := func(format string, v uint64) string {
+		if v == 0 {
+			return ""
+		}
+		return fmt.Sprintf(format, v)
+	}
+	return [][2]string{
+		{"Board ID", strings.Join(m.BoardProduct, ", ")},
+		{"BIOS Version", m.BIOSVersion},
+		{"BIOS Date", m.BIOSReleaseDate},
+		{"Chassis Type", hex("0x%02X", uint64(m.ChassisType))},
+		{"Asset Tag", m.ChassisAssetTag},
+		{"FW Features", hex("0x%X", m.FirmwareFeatures)},
+		{"FW Mask", hex("0x%X", m.FirmwareFeaturesMask)},
+		{"SMC Revision", smcBytesString(m.SmcRevision)},
+		{"SMC Branch", smcBytesString(m.SmcBranch)},
+		{"SMC Platform", smcBytesString(m.SmcPlatform)},
+	}
+}
+
+// fields returns the label and value of each known field
+func (m *SMBIOSInfo) fields() [][2]string {
+	var ret [][2]string
+	for _, f := range m.allFields() {
+		if f[1] != "" {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+// Print prints the known fields in the --list format
+func (m *SMBIOSInfo) Print() {
+	for _, f := range m.fields() {
+		fmt.Printf("%14s: %s\n", f[0], f[1])
+	}
+}
+
+// names of the values accepted by --show, in the allFields order
+var SMBIOSFieldNames = []string{
+	"board-id", "bios-version", "bios-date", "chassis-type", "asset-tag",
+	"fw-features", "fw-mask", "smc-revision", "smc-branch", "smc-platform",
+}
+
+// showSMBIOSField returns the value of the named field of the model, board-ids are returned one per entry
+func showSMBIOSField(model AppleModel, name string) ([]string, error) {
+	info := modelSMBIOS(model)
+	for i, field := range info.allFields() {
+		if SMBIOSFieldNames[i] != strings.ToLower(name) {
+			continue
+		}
+		if field[1] == "" {
+			return nil, fmt.Errorf("%s %s is unknown", ApplePlatformData[model].productName, field[0])
+		}
+		if i == 0 {
+			return info.BoardProduct, nil
+		}
+		return []string{field[1]}, nil
+	}
+	return nil, fmt.Errorf("Unknown value %s, valid values: %s", name, strings.Join(SMBIOSFieldNames, ", "))
+}
+
+// the --board-id output in JSON format
+type BoardIDMatch struct {
+	BoardID string   `json:"board_id"`
+	Models  []string `json:"models"`
+}
+
+// findBoardID returns the models using the board-id, some board-ids are shared by more than one model
+func findBoardID(id string) []int {
+	var models []int
+	for i := range AppleSMBIOSInfo {
+		for _, b := range AppleSMBIOSInfo[i].BoardProduct {
+			if strings.EqualFold(b, id) {
+				models = append(models, i)
+				break
+			}
+		}
+	}
+	return models
+}
+
+// hasBoardIDs checks if any model has a known board-id
+func hasBoardIDs() bool {
+	for i := range AppleSMBIOSInfo {
+		if len(AppleSMBIOSInfo[i].BoardProduct) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,460 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+// genmodels regenerates modelinfo_autogen.go from the OpenCorePkg AppleModels database
+//
+// It replaces scripts/update_generated.py and reads the YAML files directly
+// from an AppleModels checkout, no Python or OpenCorePkg copy needed
+//
+//	go run ./tools/genmodels -models ../OpenCorePkg/AppleModels -out modelinfo_autogen.go
+//
+// Use -check to verify that the committed file is up to date.
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// product status in the products database
+const STATUS_OK = "ok"
+
+// the fields of the AppleModels YAML files used by the generator
+type Model struct {
+	SystemProductName  string   `yaml:"SystemProductName"`
+	SystemSerialNumber string   `yaml:"SystemSerialNumber"`
+	AppleModelCode     []string `yaml:"AppleModelCode"`
+	AppleBoardCode     []string `yaml:"AppleBoardCode"`
+	AppleModelYear     []int    `yaml:"AppleModelYear"`
+	MacserialModelYear int      `yaml:"MacserialModelYear"`
+	Specifications     struct {
+		CPU []string `yaml:"CPU"`
+	} `yaml:"Specifications"`
+
+	// SMBIOS and DataHub values
+	BoardProduct                 stringList `yaml:"BoardProduct"`
+	BIOSVersion                  string     `yaml:"BIOSVersion"`
+	BIOSReleaseDate              string     `yaml:"BIOSReleaseDate"`
+	ChassisType                  int        `yaml:"ChassisType"`
+	ChassisAssetTag              string     `yaml:"ChassisAssetTag"`
+	FirmwareFeatures             uint64     `yaml:"FirmwareFeatures"`
+	FirmwareFeaturesMask         uint64     `yaml:"FirmwareFeaturesMask"`
+	ExtendedFirmwareFeatures     *uint64    `yaml:"ExtendedFirmwareFeatures"`
+	ExtendedFirmwareFeaturesMask *uint64    `yaml:"ExtendedFirmwareFeaturesMask"`
+	SmcRevision                  []int      `yaml:"SmcRevision"`
+	SmcBranch                    []int      `yaml:"SmcBranch"`
+	SmcPlatform                  []int      `yaml:"SmcPlatform"`
+
+	// first and last supported macOS releases, no maximum if still supported
+	MinimumOSVersion string `yaml:"MinimumOSVersion"`
+	MaximumOSVersion string `yaml:"MaximumOSVersion"`
+}
+
+// stringList accepts a single string or a list of strings
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = stringList{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+type Product struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// loadModels loads all the YAML files under dir sorted by product name
+func loadModels(dir string) ([]Model, error) {
+	var models []Model
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".yaml" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var m Model
+		if err := yaml.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("Failed to parse file %s - %s", path, err)
+		}
+		if m.SystemProductName == "" {
+			fmt.Fprintf(os.Stderr, "WARN: Missing SystemProductName in %s, skipping!\n", path)
+			return nil
+		}
+		if len(m.Specifications.CPU) == 0 {
+			return fmt.Errorf("Missing CPU in %s", path)
+		}
+		models = append(models, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("Empty database in %s", dir)
+	}
+	// sorting is required for fast lookup
+	sort.SliceStable(models, func(i, j int) bool {
+		return models[i].SystemProductName < models[j].SystemProductName
+	})
+	return models, nil
+}
+
+// loadProducts loads the products database, either zlib compressed (Products.zjson) or plain JSON
+func loadProducts(path string) (map[string]Product, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 && data[0] != '{' {
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		data, err = io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	products := make(map[string]Product)
+	if err := json.Unmarshal(data, &products); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return products, nil
+}
+
+// validate checks the model codes against the products database like update_generated.py
+func validate(models []Model, products map[string]Product) error {
+	used := make(map[string]bool)
+	for _, m := range models {
+		for _, code := range m.AppleModelCode {
+			switch {
+			case code == "":
+				return fmt.Errorf("%s contains empty AppleModelCode", m.SystemProductName)
+			case code == "000" || code == "0000":
+				fmt.Fprintf(os.Stderr, "WARN: %s contains zero AppleModelCode, skipping!\n", m.SystemProductName)
+				continue
+			case used[code]:
+				return fmt.Errorf("%s shares AppleModelCode %s with other model", m.SystemProductName, code)
+			}
+			used[code] = true
+			p, ok := products[code]
+			if !ok {
+				return fmt.Errorf("Model %s is used in DataBase but not present in Products", code)
+			}
+			if p.Status != STATUS_OK {
+				return fmt.Errorf("Model %s is used in DataBase but not valid in Products", code)
+			}
+		}
+		for _, code := range m.AppleBoardCode {
+			if code == "000" || code == "0000" {
+				fmt.Fprintf(os.Stderr, "WARN: %s contains zero AppleBoardCode, skipping!\n", m.SystemProductName)
+			}
+		}
+	}
+	codes := make([]string, 0, len(products))
+	for code := range products {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		p := products[code]
+		if p.Status != STATUS_OK || used[code] || len(code) <= 3 {
+			continue
+		}
+		if (strings.Contains(p.Name, "Mac") || strings.Contains(p.Name, "Xserve")) && !strings.Contains(p.Name, "M1") {
+			fmt.Fprintf(os.Stderr, "WARN: Model %s (%s) is known but is not used in DataBase!\n", code, p.Name)
+		}
+	}
+	return nil
+}
+
+// characters decomposed by NFKD that show up in product names
+var accents = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a",
+	"À", "A", "Á", "A", "Â", "A", "Ã", "A", "Ä", "A", "Å", "A",
+	"è", "e", "é", "e", "ê", "e", "ë", "e", "È", "E", "É", "E", "Ê", "E", "Ë", "E",
+	"ì", "i", "í", "i", "î", "i", "ï", "i", "Ì", "I", "Í", "I", "Î", "I", "Ï", "I",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "Ò", "O", "Ó", "O", "Ô", "O", "Õ", "O", "Ö", "O",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "Ù", "U", "Ú", "U", "Û", "U", "Ü", "U",
+	"ç", "c", "Ç", "C", "ñ", "n", "Ñ", "N", "ý", "y", "ÿ", "y", "Ý", "Y",
+	"\u00a0", " ", "™", "TM",
+)
+
+func hexList(values []int) string {
+	str := make([]string, len(values))
+	for i, v := range values {
+		str[i] = fmt.Sprintf("0x%02X", v)
+	}
+	return strings.Join(str, ", ")
+}
+
+// smbiosLiteral returns the SMBIOSInfo literal of the model with only the known fields
+// the extended firmware features take precedence like in OcMacInfoLib
+func smbiosLiteral(m Model) string {
+	var fields []string
+	add := func(format string, a ...interface{}) {
+		fields = append(fields, fmt.Sprintf(format, a...))
+	}
+	if len(m.BoardProduct) > 0 {
+		quoted := make([]string, len(m.BoardProduct))
+		for i, b := range m.BoardProduct {
+			quoted[i] = fmt.Sprintf("%q", b)
+		}
+		add("BoardProduct: []string{%s}", strings.Join(quoted, ", "))
+	}
+	if m.BIOSVersion != "" {
+		add("BIOSVersion: %q", m.BIOSVersion)
+	}
+	if m.BIOSReleaseDate != "" {
+		add("BIOSReleaseDate: %q", m.BIOSReleaseDate)
+	}
+	if m.ChassisType != 0 {
+		add("ChassisType: 0x%02X", m.ChassisType)
+	}
+	if m.ChassisAssetTag != "" {
+		add("ChassisAssetTag: %q", m.ChassisAssetTag)
+	}
+	features, mask := m.FirmwareFeatures, m.FirmwareFeaturesMask
+	if m.ExtendedFirmwareFeatures != nil {
+		features = *m.ExtendedFirmwareFeatures
+	}
+	if m.ExtendedFirmwareFeaturesMask != nil {
+		mask = *m.ExtendedFirmwareFeaturesMask
+	}
+	if features != 0 {
+		add("FirmwareFeatures: 0x%X", features)
+	}
+	if mask != 0 {
+		add("FirmwareFeaturesMask: 0x%X", mask)
+	}
+	if len(m.SmcRevision) > 0 {
+		add("SmcRevision: []int{%s}", hexList(m.SmcRevision))
+	}
+	if len(m.SmcBranch) > 0 {
+		add("SmcBranch: []int{%s}", hexList(m.SmcBranch))
+	}
+	if len(m.SmcPlatform) > 0 {
+		add("SmcPlatform: []int{%s}", hexList(m.SmcPlatform))
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// macOSLiteral returns the MacOSSupport composite literal of the model
+func macOSLiteral(m Model) string {
+	var fields []string
+	if m.MinimumOSVersion != "" {
+		fields = append(fields, fmt.Sprintf("Min: %q", m.MinimumOSVersion))
+	}
+	if m.MaximumOSVersion != "" {
+		fields = append(fields, fmt.Sprintf("Max: %q", m.MaximumOSVersion))
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+func quoteJoin(values []string) string {
+	return `"` + strings.Join(values, `", "`) + `"`
+}
+
+// generate returns the modelinfo_autogen.go contents, the layout matches update_generated.py
+// with the SMBIOS values added in AppleSMBIOSInfo, the supported macOS versions in AppleMacOSSupport
+// and the CPU of each model in AppleModelCPU
+func generate(models []Model, products map[string]Product) []byte {
+	var b bytes.Buffer
+	maxCodes, maxBoards, maxYears := 0, 0, 0
+	for _, m := range models {
+		if len(m.AppleModelCode) > maxCodes {
+			maxCodes = len(m.AppleModelCode)
+		}
+		if len(m.AppleBoardCode) > maxBoards {
+			maxBoards = len(m.AppleBoardCode)
+		}
+		if len(m.AppleModelYear) > maxYears {
+			maxYears = len(m.AppleModelYear)
+		}
+	}
+
+	b.WriteString("// DO NOT EDIT! This is an autogenerated file.\n\n")
+	b.WriteString("package main\n\n")
+	b.WriteString("const (\n")
+	for i, m := range models {
+		fmt.Fprintf(&b, "  %s = %d // %s\n", strings.ReplaceAll(m.SystemProductName, ",", "_"), i, m.Specifications.CPU[0])
+	}
+	b.WriteString(")\n\n")
+	fmt.Fprintf(&b, "const APPLE_MODEL_MAX = %d\n\n", len(models))
+
+	b.WriteString("var ApplePlatformData = []PlatformData{\n")
+	for _, m := range models {
+		fmt.Fprintf(&b, "  { \"%s\", \"%s\" },\n", m.SystemProductName, m.SystemSerialNumber)
+	}
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(&b, "const APPLE_MODEL_CODE_MAX = %d\n", maxCodes)
+	b.WriteString("var AppleModelCode = [][APPLE_MODEL_CODE_MAX]string{\n")
+	for _, m := range models {
+		fmt.Fprintf(&b, "  /* %-14s */ {%s},\n", m.SystemProductName, quoteJoin(m.AppleModelCode))
+	}
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(&b, "const APPLE_BOARD_CODE_MAX = %d\n", maxBoards)
+	b.WriteString("var AppleBoardCode = [][APPLE_BOARD_CODE_MAX]string{\n")
+	for _, m := range models {
+		fmt.Fprintf(&b, "  /* %-14s */ {%s},\n", m.SystemProductName, quoteJoin(m.AppleBoardCode))
+	}
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(&b, "const APPLE_MODEL_YEAR_MAX = %d\n", maxYears)
+	b.WriteString("var AppleModelYear = [][APPLE_MODEL_YEAR_MAX]uint32{\n")
+	for _, m := range models {
+		years := make([]string, len(m.AppleModelYear))
+		for i, y := range m.AppleModelYear {
+			years[i] = fmt.Sprint(y)
+		}
+		fmt.Fprintf(&b, "  /* %-14s */ {%s},\n", m.SystemProductName, strings.Join(years, ", "))
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("var ApplePreferredModelYear = []uint32{\n")
+	for _, m := range models {
+		fmt.Fprintf(&b, "  /* %-14s */ %d,\n", m.SystemProductName, m.MacserialModelYear)
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("var AppleSMBIOSInfo = []SMBIOSInfo{\n")
+	for _, m := range models {
+		fmt.Fprintf(&b, "  /* %-14s */ %s,\n", m.SystemProductName, smbiosLiteral(m))
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("var AppleMacOSSupport = []MacOSSupport{\n")
+	for _, m := range models {
+		fmt.Fprintf(&b, "  /* %-14s */ %s,\n", m.SystemProductName, macOSLiteral(m))
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("var AppleModelCPU = []string{\n")
+	for _, m := range models {
+		fmt.Fprintf(&b, "  /* %-14s */ %q,\n", m.SystemProductName, m.Specifications.CPU[0])
+	}
+	b.WriteString("}\n\n")
+
+	// sorted by length and then alphabetically
+	codes := make([]string, 0, len(products))
+	for code, p := range products {
+		if p.Status == STATUS_OK {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	sort.SliceStable(codes, func(i, j int) bool { return len(codes[i]) < len(codes[j]) })
+	b.WriteString("var AppleModelDesc = []AppleModelDescription{\n")
+	for _, code := range codes {
+		fmt.Fprintf(&b, " {\"%s\", \"%s\"},\n", code, accents.Replace(products[code].Name))
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+func main() {
+	var dir, productsPath, out string
+	var check bool
+	flag.StringVar(&dir, "models", "AppleModels", "AppleModels directory")
+	flag.StringVar(&productsPath, "products", "", "products database (default <models>/Products.zjson)")
+	flag.StringVar(&out, "out", "modelinfo_autogen.go", "output file (- for stdout)")
+	flag.BoolVar(&check, "check", false, "fail if the output file is not up to date")
+	flag.Parse()
+
+	if productsPath == "" {
+		productsPath = filepath.Join(dir, "Products.zjson")
+	}
+	models, err := loadModels(filepath.Join(dir, "DataBase"))
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	products, err := loadProducts(productsPath)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	if err := validate(models, products); err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	src := generate(models, products)
+
+	switch {
+	case check:
+		current, err := os.ReadFile(out)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		if !bytes.Equal(current, src) {
+			fmt.Printf("ERROR: %s is not up to date, run go generate\n", out)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "%s is up to date\n", out)
+	case out == "-":
+		os.Stdout.Write(src)
+	default:
+		if err := os.WriteFile(out, src, 0644); err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Generated %d models and %d products\n", len(models), len(products))
+	}
+}
@@ -0,0 +1,208 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testModels = map[string]string{
+	"Mac/iMac1,1.yaml": `SystemProductName: iMac1,1
+SystemSerialNumber: W8031AAAAAA
+Specifications:
+  CPU:
+    - Intel Core 2 Duo
+AppleModelCode:
+  - "AAA"
+AppleBoardCode:
+  - ""
+AppleModelYear:
+  - 2007
+BoardProduct: Mac-F42786C8
+MinimumOSVersion: 10.4.10
+MaximumOSVersion: 10.6.8
+`,
+	"Mac/MacPro1,1.yaml": `SystemProductName: MacPro1,1
+SystemSerialNumber: C02BBBBBBBBB
+Specifications:
+  CPU:
+    - Intel Xeon
+AppleModelCode:
+  - "BBBB"
+  - "CCCC"
+AppleBoardCode:
+  - "000"
+  - "DDDD"
+AppleModelYear:
+  - 2010
+  - 2011
+MacserialModelYear: 2010
+BoardProduct:
+  - Mac-F221BEC8
+  - Mac-F4208DC8
+BIOSVersion: MP51.88Z.F000.B00.1904121248
+BIOSReleaseDate: 04/12/2019
+ChassisType: 0x07
+ChassisAssetTag: Pro-Enclosure
+FirmwareFeatures: 0xE80FE137
+ExtendedFirmwareFeatures: 0x8E80FE137
+FirmwareFeaturesMask: 0xFF1FFF3F
+SmcRevision: [0x01, 0x39, 0x0F, 0x05, 0x00, 0x00]
+SmcBranch: [0x6B, 0x35, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00]
+MinimumOSVersion: 10.6.4
+MaximumOSVersion: null
+`,
+	"README.md": "not a model",
+}
+
+const testProducts = `{"AAA": {"name": "iMac (20-inch, Mid 2007)", "status": "ok"},
+"BBBB": {"name": "Mac Pro (Mid 2010)", "status": "ok"},
+"CCCC": {"name": "Mac Pro (Mid 2010) Écran", "status": "ok"},
+"ZZZZ": {"name": "iPhone", "status": "invalid"}}`
+
+const testOutput = `// DO NOT EDIT! This is an autogenerated file.
+
+package main
+
+const (
+  MacPro1_1 = 0 // Intel Xeon
+  iMac1_1 = 1 // Intel Core 2 Duo
+)
+
+const APPLE_MODEL_MAX = 2
+
+var ApplePlatformData = []PlatformData{
+  { "MacPro1,1", "C02BBBBBBBBB" },
+  { "iMac1,1", "W8031AAAAAA" },
+}
+
+const APPLE_MODEL_CODE_MAX = 2
+var AppleModelCode = [][APPLE_MODEL_CODE_MAX]string{
+  /* MacPro1,1      */ {"BBBB", "CCCC"},
+  /* iMac1,1        */ {"AAA"},
+}
+
+const APPLE_BOARD_CODE_MAX = 2
+var AppleBoardCode = [][APPLE_BOARD_CODE_MAX]string{
+  /* MacPro1,1      */ {"000", "DDDD"},
+  /* iMac1,1        */ {""},
+}
+
+const APPLE_MODEL_YEAR_MAX = 2
+var AppleModelYear = [][APPLE_MODEL_YEAR_MAX]uint32{
+  /* MacPro1,1      */ {2010, 2011},
+  /* iMac1,1        */ {2007},
+}
+
+var ApplePreferredModelYear = []uint32{
+  /* MacPro1,1      */ 2010,
+  /* iMac1,1        */ 0,
+}
+
+var AppleSMBIOSInfo = []SMBIOSInfo{
+  /* MacPro1,1      */ {BoardProduct: []string{"Mac-F221BEC8", "Mac-F4208DC8"}, BIOSVersion: "MP51.88Z.F000.B00.1904121248", BIOSReleaseDate: "04/12/2019", ChassisType: 0x07, ChassisAssetTag: "Pro-Enclosure", FirmwareFeatures: 0x8E80FE137, FirmwareFeaturesMask: 0xFF1FFF3F, SmcRevision: []int{0x01, 0x39, 0x0F, 0x05, 0x00, 0x00}, SmcBranch: []int{0x6B, 0x35, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+  /* iMac1,1        */ {BoardProduct: []string{"Mac-F42786C8"}},
+}
+
+var AppleMacOSSupport = []MacOSSupport{
+  /* MacPro1,1      */ {Min: "10.6.4"},
+  /* iMac1,1        */ {Min: "10.4.10", Max: "10.6.8"},
+}
+
+var AppleModelCPU = []string{
+  /* MacPro1,1      */ "Intel Xeon",
+  /* iMac1,1        */ "Intel Core 2 Duo",
+}
+
+var AppleModelDesc = []AppleModelDescription{
+ {"AAA", "iMac (20-inch, Mid 2007)"},
+ {"BBBB", "Mac Pro (Mid 2010)"},
+ {"CCCC", "Mac Pro (Mid 2010) Ecran"},
+}
+`
+
+func writeTestDB(t *testing.T) string {
+	dir := t.TempDir()
+	for name, data := range testModels {
+		path := filepath.Join(dir, "DataBase", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Products.json"), []byte(testProducts), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestGenerate(t *testing.T) {
+	dir := writeTestDB(t)
+	models, err := loadModels(filepath.Join(dir, "DataBase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	products, err := loadProducts(filepath.Join(dir, "Products.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate(models, products); err != nil {
+		t.Fatal(err)
+	}
+	if src := string(generate(models, products)); src != testOutput {
+		t.Fatalf("Bad output\n%s", src)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := writeTestDB(t)
+	models, err := loadModels(filepath.Join(dir, "DataBase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	products := map[string]Product{"AAA": {Name: "iMac", Status: STATUS_OK}, "BBBB": {Name: "Mac Pro", Status: STATUS_OK}}
+	if err := validate(models, products); err == nil || !strings.Contains(err.Error(), "CCCC") {
+		t.Fatalf("Missing product should fail: %v", err)
+	}
+	models[0].AppleModelCode = []string{"BBBB"}
+	models[1].AppleModelCode = []string{"BBBB"}
+	if err := validate(models, products); err == nil || !strings.Contains(err.Error(), "shares") {
+		t.Fatalf("Shared model code should fail: %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+// genromprefix regenerates the AppleRomPrefix table from a Wireshark manuf file
+//
+// The manuf file is not downloaded, get a copy from
+// https://www.wireshark.org/download/automated/data/manuf and run
+//
+//	go run ./tools/genromprefix -manuf manuf -out romprefix_autogen.go
+//
+// Only /24 blocks (MA-L) are used by default because the ROM generator
+// randomizes the lower 3 bytes. Larger blocks can be expanded with -larger,
+// smaller blocks (MA-M and MA-S) are always skipped since random addresses
+// would fall outside of them.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Block struct {
+	Prefix []byte
+	Bits   int
+	Vendor string
+}
+
+// parseLine parses a manuf line, returns nil for comments and empty lines
+// the format is prefix[/bits] <tab> short name [<tab> long name]
+func parseLine(line string) (*Block, error) {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Split(strings.TrimSpace(line), "\t")
+	if len(fields) == 1 {
+		// older files used spaces
+		fields = strings.Fields(fields[0])
+	}
+	if len(fields) == 0 || fields[0] == "" {
+		return nil, nil
+	}
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("missing vendor in %q", line)
+	}
+	prefix := fields[0]
+	bits := -1
+	if i := strings.IndexByte(prefix, '/'); i >= 0 {
+		n, err := strconv.Atoi(prefix[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("bad mask in %q", line)
+		}
+		bits = n
+		prefix = prefix[:i]
+	}
+	raw, err := hex.DecodeString(strings.NewReplacer(":", "", "-", "", ".", "").Replace(prefix))
+	if err != nil || len(raw) == 0 || len(raw) > 6 {
+		return nil, fmt.Errorf("bad prefix in %q", line)
+	}
+	if bits < 0 {
+		bits = len(raw) * 8
+	}
+	if bits < 8 || bits > 48 {
+		return nil, fmt.Errorf("bad mask in %q", line)
+	}
+	return &Block{Prefix: raw, Bits: bits, Vendor: strings.TrimSpace(fields[1])}, nil
+}
+
+// applePrefixes returns the sorted /24 prefixes assigned to vendor
+// blocks larger than /24 are expanded if larger is set, the number of skipped blocks is also returned
+func applePrefixes(r io.Reader, vendor string, larger bool) ([]string, int, error) {
+	seen := make(map[string]bool)
+	skipped := 0
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		b, err := parseLine(scanner.Text())
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %s", line, err)
+		}
+		if b == nil || !strings.EqualFold(b.Vendor, vendor) {
+			continue
+		}
+		if b.Bits > 24 || (b.Bits < 24 && !larger) {
+			skipped++
+			continue
+		}
+		// pad to 3 bytes and expand the host bits of larger blocks
+		base := make([]byte, 3)
+		copy(base, b.Prefix)
+		start := uint32(base[0])<<16 | uint32(base[1])<<8 | uint32(base[2])
+		count := uint32(1) << (24 - b.Bits)
+		start &^= count - 1
+		for i := uint32(0); i < count; i++ {
+			seen[fmt.Sprintf("%06X", start+i)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	prefixes := make([]string, 0, len(seen))
+	for p := range seen {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+	return prefixes, skipped, nil
+}
+
+// generate returns the formatted Go source for the table
+func generate(prefixes []string, source string, date string) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by tools/genromprefix; DO NOT EDIT.\n")
+	fmt.Fprintf(&b, "// Source: %s, generated on %s\n\n", source, date)
+	fmt.Fprintf(&b, "package main\n\n")
+	fmt.Fprintf(&b, "// Apple assigned MAC address prefixes from the Wireshark manuf file\n")
+	fmt.Fprintf(&b, "var AppleRomPrefix = []string{\n")
+	for _, p := range prefixes {
+		fmt.Fprintf(&b, "%q,\n", p)
+	}
+	fmt.Fprintf(&b, "}\n")
+	return format.Source(b.Bytes())
+}
+
+func main() {
+	var manuf, out, vendor, source, date string
+	var larger bool
+	flag.StringVar(&manuf, "manuf", "manuf", "Wireshark manuf file")
+	flag.StringVar(&out, "out", "romprefix_autogen.go", "output file (- for stdout)")
+	flag.StringVar(&vendor, "vendor", "Apple", "vendor short name")
+	flag.StringVar(&source, "source", "", "source named in the header (default the manuf file name)")
+	flag.StringVar(&date, "date", time.Now().Format("2006-01-02"), "generation date in the header")
+	flag.BoolVar(&larger, "larger", false, "expand blocks larger than /24")
+	flag.Parse()
+
+	f, err := os.Open(manuf)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	prefixes, skipped, err := applePrefixes(f, vendor, larger)
+	f.Close()
+	if err != nil {
+		fmt.Printf("ERROR: %s: %s\n", manuf, err)
+		os.Exit(1)
+	}
+	if len(prefixes) == 0 {
+		fmt.Printf("ERROR: no %s prefixes found in %s\n", vendor, manuf)
+		os.Exit(1)
+	}
+	if source == "" {
+		source = filepath.Base(manuf)
+	}
+	src, err := generate(prefixes, source, date)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	if out == "-" {
+		os.Stdout.Write(src)
+	} else if err := os.WriteFile(out, src, 0644); err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d %s blocks that are not /24\n", skipped, vendor)
+	}
+	fmt.Fprintf(os.Stderr, "Found %d %s prefixes\n", len(prefixes), vendor)
+}
@@ -0,0 +1,79 @@
+//
+// SMBIOSKeygen
+//
+// Copyright (c) 2022 Pedro Vilaça
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+// list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation and/
+// or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testManuf = `# Wireshark manuf sample
+00:00:0C	Cisco	Cisco Systems, Inc
+00:03:93	Apple	Apple, Inc.
+00:03:93	Apple	Apple, Inc.
+00-0A-27	Apple
+AC:DE:48:00:00:00/20	Apple	Apple, Inc.
+70:B3:D5:00:00:00/36	Apple	Apple, Inc.
+FC:FC:48	Apple	Apple, Inc.
+`
+
+func TestApplePrefixes(t *testing.T) {
+	prefixes, skipped, err := applePrefixes(strings.NewReader(testManuf), "Apple", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(prefixes, ",") != "000393,000A27,FCFC48" || skipped != 2 {
+		t.Fatalf("Bad prefixes %v skipped %d", prefixes, skipped)
+	}
+	prefixes, skipped, err = applePrefixes(strings.NewReader(testManuf), "Apple", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prefixes) != 3+16 || prefixes[2] != "ACDE40" || prefixes[17] != "ACDE4F" || skipped != 1 {
+		t.Fatalf("Bad expanded prefixes %v skipped %d", prefixes, skipped)
+	}
+	if _, _, err := applePrefixes(strings.NewReader("ZZ:00:00\tApple\n"), "Apple", false); err == nil {
+		t.Fatal("Bad prefix should fail")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := generate([]string{"000393", "000A27"}, "manuf", "2022-12-14")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "generated on 2022-12-14") || !strings.Contains(string(src), "\t\"000A27\",\n}") {
+		t.Fatalf("Bad source\n%s", src)
+	}
+}